@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// keeps the capacity of the provider's cp on chain up to date
+type agent struct {
+	o     *options
+	txObj *tx.Tx
+	cp    common.Address
+
+	// the index counting the nodes with orders, nil when there is none
+	ix *tx.Index
+	// filesystem whose used space is the used disk
+	disk string
+	// bytes in a unit of the nodes' mem and disk
+	memUnit, diskUnit uint64
+	// missing node ids in a row ending the read of the nodes
+	nodeGap int
+
+	// percent of a total the usage must move to be sent
+	threshold float64
+	// least time between updates and most updates in a day
+	minInterval time.Duration
+	maxUpdates  int
+	// times of the updates made, the last day of them
+	updates []time.Time
+}
+
+// run as the provider, sending updatecp when the cp's capacity changes:
+// sendtx agent -as provider-3 -auto -threshold 10 -min-interval 30m
+func providerAgent(args []string) error {
+	fs := newFlagSet("agent")
+	o := options{}
+	o.register(fs, true)
+	o.asFlag(fs, "provider")
+	interval := fs.Duration("interval", time.Minute, "time between measures of the capacity")
+	threshold := fs.Float64("threshold", 5, "percent of its total a used value must move to be sent, totals are sent on any change")
+	minInterval := fs.Duration("min-interval", 15*time.Minute, "least time between two updatecp txs")
+	maxUpdates := fs.Int("max-updates", 24, "most updatecp txs in a day, bounding the gas spent")
+	disk := fs.String("disk", "/", "filesystem whose used space is the used disk")
+	memUnit := fs.Uint64("mem-unit", 1<<30, "bytes in a unit of the nodes' mem")
+	diskUnit := fs.Uint64("disk-unit", 1<<30, "bytes in a unit of the nodes' disk")
+	nodeGap := fs.Int("node-gap", 8, "node ids missing in a row after which no more nodes are read")
+	db := fs.String("db", "", "index counting the nodes with orders, kept in sync by the agent, default the chain's")
+	once := fs.Bool("once", false, "measure and update once, e.g. from cron")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if outputMode != outText && outputMode != outNDJSON {
+		return usageError{fmt.Errorf("agent reports each update, -output is text or ndjson")}
+	}
+	if *threshold < 0 || *maxUpdates < 1 || *nodeGap < 1 || *memUnit == 0 || *diskUnit == 0 {
+		return usageError{fmt.Errorf("-threshold, -max-updates, -node-gap, -mem-unit and -disk-unit must be positive")}
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+	restore, err := o.act("provider")
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if level := o.c.SafetyLevel(); o.auto && !tx.DryRun && level != tx.SafetyOpen && !assumeYes {
+		return refusedError{fmt.Errorf("chain %s is %s, start the agent with -yes to send", o.c.Name, level)}
+	}
+
+	a := &agent{
+		o:           &o,
+		txObj:       txObj,
+		cp:          common.HexToAddress(tx.P_ADDR),
+		disk:        *disk,
+		memUnit:     *memUnit,
+		diskUnit:    *diskUnit,
+		nodeGap:     *nodeGap,
+		threshold:   *threshold,
+		minInterval: *minInterval,
+		maxUpdates:  *maxUpdates,
+	}
+
+	if *db == "" {
+		*db = indexPath(o.c.Label())
+	}
+	if _, err := os.Stat(*db); err == nil {
+		if a.ix, err = tx.OpenIndex(*db); err != nil {
+			return fmt.Errorf("%w, the agent keeps the index itself, stop sendtx index -follow", err)
+		}
+		defer a.ix.Close()
+	} else {
+		log.Printf("agent: no index at %s, uNode is kept as on chain, run sendtx index to count the nodes with orders", *db)
+	}
+
+	log.Printf("agent: cp %s, updates at least %s apart and at most %d a day", a.cp.Hex(), a.minInterval, a.maxUpdates)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for {
+		err := a.tick()
+		if *once {
+			return err
+		}
+		if err != nil {
+			log.Printf("agent: %v, retrying", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// measure the capacity and update the cp when it changed enough and the limits allow
+func (a *agent) tick() error {
+	info, err := a.txObj.GetCP(a.cp)
+	if err != nil {
+		return err
+	}
+	if info.Addr != a.cp {
+		return fmt.Errorf("cp %s is not registered, run sendtx register first", a.cp.Hex())
+	}
+
+	// compared with the chain, so a failed or unsent update is tried again
+	onChain := tx.CPCapacity(info)
+	now, err := a.measure(onChain)
+	if err != nil {
+		return err
+	}
+	changes := now.Changes(onChain, a.threshold)
+	if len(changes) == 0 {
+		return nil
+	}
+	if wait := a.wait(time.Now()); wait > 0 {
+		log.Printf("agent: %s, rate limited for %s", strings.Join(changes, ", "), wait.Round(time.Second))
+		return nil
+	}
+	log.Printf("agent: updating cp: %s", strings.Join(changes, ", "))
+
+	now.Apply(info)
+	sender := a.txObj.Fork()
+	// the receipt is waited for, or nothing was sent, the next nonce is the chain's
+	defer tx.Nonces.Forget(a.cp)
+	if err := sender.MakeUpdateCPTx(info); err != nil {
+		return err
+	}
+	// counted even when sending fails, it may have spent gas
+	a.updates = append(a.updates, time.Now())
+
+	return a.o.finish(sender, "updatecp")
+}
+
+// the capacity now: totals of the registered nodes, used nodes from the index, used mem and disk of the host
+func (a *agent) measure(onChain tx.Capacity) (tx.Capacity, error) {
+	nodes, err := a.txObj.CPNodes(a.cp, a.nodeGap)
+	if err != nil {
+		return tx.Capacity{}, err
+	}
+	c := tx.NodeTotals(nodes)
+
+	c.UNode = onChain.UNode
+	if a.ix != nil {
+		if _, _, err := a.txObj.SyncIndex(a.ix, a.o.c.Label(), 0, 6); err != nil {
+			return tx.Capacity{}, err
+		}
+		used, err := a.txObj.UsedNodes(a.ix, a.cp)
+		if err != nil {
+			return tx.Capacity{}, err
+		}
+		c.UNode = 0
+		for _, n := range nodes {
+			if used[n.Id] {
+				c.UNode++
+			}
+		}
+	}
+
+	mem, disk, err := hostUsage(a.disk)
+	if err != nil {
+		return tx.Capacity{}, err
+	}
+	c.UMem, c.UDisk = min(mem/a.memUnit, c.NMem), min(disk/a.diskUnit, c.NDisk)
+
+	return c, nil
+}
+
+// how long until the next update is allowed, 0 when it is
+func (a *agent) wait(now time.Time) time.Duration {
+	day := now.Add(-24 * time.Hour)
+	for len(a.updates) > 0 && a.updates[0].Before(day) {
+		a.updates = a.updates[1:]
+	}
+	if len(a.updates) == 0 {
+		return 0
+	}
+
+	wait := a.updates[len(a.updates)-1].Add(a.minInterval).Sub(now)
+	if len(a.updates) >= a.maxUpdates {
+		if w := a.updates[0].Add(24 * time.Hour).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	if wait < 0 {
+		return 0
+	}
+
+	return wait
+}
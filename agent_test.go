@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAgentWait(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	for _, tc := range []struct {
+		name    string
+		updates []time.Time
+		want    time.Duration
+		kept    int
+	}{
+		{"no updates", nil, 0, 0},
+		{"last update past the min interval", []time.Time{ago(time.Hour)}, 0, 1},
+		{"last update within the min interval", []time.Time{ago(10 * time.Minute)}, 5 * time.Minute, 1},
+		{"updates of yesterday dropped", []time.Time{ago(25 * time.Hour), ago(24*time.Hour + time.Second)}, 0, 0},
+		// the third update a day waits for the oldest to leave the day
+		{"max updates in a day", []time.Time{ago(23 * time.Hour), ago(2 * time.Hour), ago(time.Hour)}, time.Hour, 3},
+	} {
+		a := &agent{minInterval: 15 * time.Minute, maxUpdates: 3, updates: tc.updates}
+		if got := a.wait(now); got != tc.want || len(a.updates) != tc.kept {
+			t.Errorf("%s: wait %s keeping %d updates, want %s keeping %d", tc.name, got, len(a.updates), tc.want, tc.kept)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// the views compare reads, by the kind of record
+var compareViews = map[string]struct {
+	view *tx.View
+	// names of the positional args
+	args []string
+}{
+	"cp":    {tx.CPView, []string{"cp"}},
+	"order": {tx.OrderView, []string{"user", "provider"}},
+}
+
+// compare a cp or an order between deployments of a chain:
+// sendtx compare cp <cp> | sendtx compare order <user> <provider>
+func compare(args []string) error {
+	fs := newFlagSet("compare")
+	o := options{}
+	o.register(fs, false)
+	deployments := fs.String("deployments", "", "comma separated deployments to compare, e.g. v1,v2, all of the chain's when empty")
+	usage := fs.Usage
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintln(fs.Output(), "\naccounts are addresses, aliases or account profiles, differing fields are marked with *")
+	}
+
+	pos, err := parseFlags(fs, args, 2, 3)
+	if err != nil {
+		return err
+	}
+	view, ok := compareViews[pos[0]]
+	if !ok {
+		return usageError{fmt.Errorf("compare: unknown record %q, want cp or order", pos[0])}
+	}
+	if len(pos)-1 != len(view.args) {
+		return usageError{fmt.Errorf("compare %s wants %s", pos[0], strings.Join(view.args, " and "))}
+	}
+
+	base, err := o.baseChain()
+	if err != nil {
+		return err
+	}
+	names := base.DeploymentNames()
+	if *deployments != "" {
+		names = strings.Split(*deployments, ",")
+	}
+	if len(names) < 2 {
+		return usageError{fmt.Errorf("chain %s has deployments %v, compare needs two", base.Name, names)}
+	}
+
+	var viewArgs []interface{}
+	for _, s := range pos[1:] {
+		addr, err := tx.ResolveAddress(s)
+		if err != nil {
+			return usageError{err}
+		}
+		viewArgs = append(viewArgs, addr)
+	}
+
+	// one connection, the contracts and abis are swapped per deployment
+	var txObj *tx.Tx
+	var snaps []*tx.Snapshot
+	for _, name := range names {
+		c, err := base.Select(strings.TrimSpace(name))
+		if err != nil {
+			return usageError{err}
+		}
+		if err := c.Use(); err != nil {
+			return err
+		}
+		if txObj == nil {
+			txObj = tx.NewTx(c.RPC)
+		}
+		if err := txObj.CheckChain(c); err != nil {
+			return err
+		}
+
+		// each deployment's abi names its view
+		method := view.view.Name
+		if m, err := view.view.Method(); err == nil {
+			method = m
+		}
+		snap, err := txObj.Snapshot(c.Deployment, view.view.Contract, method, viewArgs...)
+		if err != nil {
+			return err
+		}
+		snaps = append(snaps, snap)
+	}
+
+	rows, diffs := compareRows(snaps)
+
+	return emit(&Result{
+		Op:    "compare",
+		Chain: base.Name,
+		Data: map[string]interface{}{
+			"record":      pos[0],
+			"key":         pos[1:],
+			"deployments": snaps,
+			"differences": diffs,
+		},
+		text: compareText(snaps, rows, diffs),
+	})
+}
+
+// the flattened fields of the snapshots, a row of values per field,
+// and the fields whose values differ
+func compareRows(snaps []*tx.Snapshot) (map[string][]string, []string) {
+	rows := map[string][]string{}
+	for i, s := range snaps {
+		for name, v := range s.Fields {
+			flatten(name, v, func(k, v string) {
+				if rows[k] == nil {
+					rows[k] = make([]string, len(snaps))
+				}
+				rows[k][i] = v
+			})
+		}
+	}
+
+	diffs := []string{}
+	for k, vals := range rows {
+		for _, v := range vals[1:] {
+			if v != vals[0] {
+				diffs = append(diffs, k)
+				break
+			}
+		}
+	}
+	sort.Strings(diffs)
+
+	return rows, diffs
+}
+
+// a table of the fields by deployment, differing fields marked with *
+func compareText(snaps []*tx.Snapshot, rows map[string][]string, diffs []string) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+
+	fmt.Fprint(w, "  field")
+	for _, s := range snaps {
+		fmt.Fprintf(w, "\t%s", s.Deployment)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprint(w, "  status")
+	for _, s := range snaps {
+		switch {
+		case s.Missing:
+			fmt.Fprint(w, "\tnot found")
+		case s.Problem != "":
+			fmt.Fprintf(w, "\t%s", s.Problem)
+		default:
+			fmt.Fprint(w, "\tok")
+		}
+	}
+	fmt.Fprintln(w)
+
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	differs := map[string]bool{}
+	for _, k := range diffs {
+		differs[k] = true
+	}
+	for _, k := range keys {
+		mark := " "
+		if differs[k] {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s", mark, k)
+		for _, v := range rows[k] {
+			fmt.Fprintf(w, "\t%s", v)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// console history, next to the token cache
+var HistoryPath = filepath.Join(filepath.Dir(tx.TokenCachePath), "console_history")
+
+// the name of the last result in console commands, $_.field for a field of it
+const lastVar = "$_"
+
+// an interactive session on one connection
+type session struct {
+	tx *tx.Tx
+	o  *options
+	// role signing calls
+	role string
+	// name of the signed tx waiting for send
+	pending string
+	// result of the last command
+	last interface{}
+}
+
+// a console command, args have $_ expanded
+type consoleCmd struct {
+	args  string
+	short string
+	run   func(s *session, args []string) error
+}
+
+var consoleCmds map[string]consoleCmd
+
+func init() {
+	consoleCmds = map[string]consoleCmd{
+		"call":    {"<contract> <method> [args...]", "run a view, or sign any other method as the current role", (*session).call},
+		"quote":   {"[order flags]", "print the cost of ordering a node", (*session).quote},
+		"inspect": {"", "print and decode the signed tx waiting for send", (*session).inspect},
+		"send":    {"", "send the signed tx and print its events", (*session).send},
+		"as":      {"[role]", "print or switch the role or account profile signing calls", (*session).as},
+		"last":    {"[field]", "print the last result, or a field of it like 0.status", (*session).printLast},
+		"help":    {"", "list the commands", (*session).help},
+	}
+}
+
+// keep one connection open and read commands at a prompt
+func console(args []string) error {
+	fs := newFlagSet("console")
+	o := options{}
+	o.register(fs, false)
+	fs.BoolVar(&tx.Strict, "strict", false, "fail on validation warnings")
+	fs.BoolVar(&tx.Preflight, "preflight", true, "check chain state before signing and refuse txs bound to fail")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if structured() {
+		return usageError{fmt.Errorf("console only prints text, -output %s is for commands", outputMode)}
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	s := &session{tx: txObj, o: &o, role: "user"}
+
+	line := liner.NewLiner()
+	defer line.Close()
+	line.SetCtrlCAborts(true)
+	line.SetTabCompletionStyle(liner.TabPrints)
+	line.SetWordCompleter(s.complete)
+
+	if f, err := os.Open(HistoryPath); err == nil {
+		line.ReadHistory(f)
+		f.Close()
+	}
+	defer s.saveHistory(line)
+
+	fmt.Println("type help for the commands, exit or ctrl-d to quit")
+	for {
+		input, err := line.Prompt(s.prompt())
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Println()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		line.AppendHistory(input)
+		if input == "exit" || input == "quit" {
+			return nil
+		}
+
+		if err := s.exec(input); err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+}
+
+func (s *session) saveHistory(line *liner.State) {
+	if err := os.MkdirAll(filepath.Dir(HistoryPath), 0o755); err != nil {
+		return
+	}
+	f, err := os.Create(HistoryPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	line.WriteHistory(f)
+}
+
+// chain, role and the pending tx in the prompt
+func (s *session) prompt() string {
+	p := s.o.c.Name + ":" + s.role
+	if s.pending != "" {
+		p += " [" + s.pending + "]"
+	}
+
+	return p + "> "
+}
+
+// run one line
+func (s *session) exec(input string) error {
+	words, err := splitWords(input)
+	if err != nil {
+		return err
+	}
+	args, err := s.expandLast(words[1:])
+	if err != nil {
+		return err
+	}
+
+	if c, ok := consoleCmds[words[0]]; ok {
+		return c.run(s, args)
+	}
+	if op, ok := txOps[words[0]]; ok {
+		return s.build(op, args)
+	}
+
+	return fmt.Errorf("unknown command %q, type help", words[0])
+}
+
+// replace $_ and $_.field with the last result
+func (s *session) expandLast(args []string) ([]string, error) {
+	out := make([]string, 0, len(args))
+	for _, a := range args {
+		if a != lastVar && !strings.HasPrefix(a, lastVar+".") {
+			out = append(out, a)
+			continue
+		}
+
+		v, err := s.lastField(strings.TrimPrefix(strings.TrimPrefix(a, lastVar), "."))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a, err)
+		}
+		out = append(out, tx.FormatValue(v))
+	}
+
+	return out, nil
+}
+
+// a field of the last result, a single output stands for its call
+func (s *session) lastField(path string) (interface{}, error) {
+	if s.last == nil {
+		return nil, fmt.Errorf("no last result")
+	}
+
+	v := s.last
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		for _, only := range m {
+			v = only
+		}
+	}
+
+	return tx.FieldPath(v, path)
+}
+
+// sign the tx of a tx command, it waits for send
+func (s *session) build(op txOp, args []string) error {
+	fs := flag.NewFlagSet(op.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	build := op.setup(fs)
+	as := fs.String("as", "", "account profile signing as the "+op.role+", the default "+op.role+" when empty")
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s takes no args: %q", op.name, fs.Args())
+	}
+
+	if *as != "" {
+		restore, err := tx.UseAccount(*as, op.role)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	s.tx.SignedTx = nil
+	s.pending = ""
+	if err := build(s.tx); err != nil {
+		return err
+	}
+	s.pending = op.label
+	s.last = s.tx.SignedTx.Hash()
+	fmt.Printf("signed %s tx %s, inspect or send it\n", op.label, s.tx.SignedTx.Hash())
+
+	return nil
+}
+
+func (s *session) call(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: call %s", consoleCmds["call"].args)
+	}
+	contract, method := args[0], args[1]
+
+	s.tx.SignedTx = nil
+	s.pending = ""
+	out, err := s.tx.Call(contract, method, args[2:], s.role)
+	if err != nil {
+		return err
+	}
+
+	// a signed tx waits for send
+	if s.tx.SignedTx != nil {
+		s.pending = contract + "." + method
+		s.last = s.tx.SignedTx.Hash()
+		fmt.Printf("signed %s tx %s as %s, inspect or send it\n", s.pending, s.tx.SignedTx.Hash(), s.role)
+		return nil
+	}
+
+	parsed, _, err := tx.ContractByName(contract)
+	if err != nil {
+		return err
+	}
+	outputs := parsed.Methods[method].Outputs
+	s.last = tx.OutputFields(outputs, out)
+	fmt.Println(tx.FormatOutputs(outputs, out))
+
+	return nil
+}
+
+func (s *session) quote(args []string) error {
+	fs := flag.NewFlagSet("quote", flag.ContinueOnError)
+	fs.SetOutput(os.Stdout)
+	spec := tx.OrderSpec{}
+	loadSpec := orderFlags(fs, &spec, false)
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := loadSpec(); err != nil {
+		return err
+	}
+
+	q, err := s.tx.QuoteOrder(&spec)
+	if err != nil {
+		return err
+	}
+	s.last = q
+	fmt.Println(q)
+
+	return nil
+}
+
+func (s *session) inspect(args []string) error {
+	if s.pending == "" {
+		return fmt.Errorf("no signed tx, build one first")
+	}
+
+	fmt.Printf("%s\n", s.tx.JsonTx)
+
+	signed := s.tx.SignedTx
+	contract, m, vals, err := tx.DecodeCall(*signed.To(), signed.Data())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s.%s\n%s\n", contract, m.Sig, tx.FormatOutputs(m.Inputs, vals))
+
+	return nil
+}
+
+func (s *session) send(args []string) error {
+	if s.pending == "" {
+		return fmt.Errorf("no signed tx, build one first")
+	}
+
+	r := s.o.txResult(s.tx, s.pending)
+	if err := s.o.allow(opName(r)); err != nil {
+		return err
+	}
+	if err := s.o.gate(r.review(s.tx)); err != nil {
+		return err
+	}
+
+	// a failed send is not retried with the same tx
+	name := s.pending
+	s.pending = ""
+	if err := s.tx.Send(); err != nil {
+		return err
+	}
+	if url := s.o.c.TxURL(s.tx.SignedTx.Hash().Hex()); url != "" {
+		fmt.Println("explorer:", url)
+	}
+
+	receipt, err := s.tx.Receipt()
+	if err != nil {
+		return err
+	}
+	events := tx.DecodeLogs(receipt.Logs)
+	s.last = map[string]interface{}{
+		"hash":    receipt.TxHash,
+		"block":   receipt.BlockNumber,
+		"gasUsed": receipt.GasUsed,
+		"status":  receipt.Status,
+		"events":  events,
+	}
+
+	fmt.Printf("%s in block %s, gas used %d\n", name, receipt.BlockNumber, receipt.GasUsed)
+	for i, ev := range events {
+		fmt.Printf("  event %d %s.%s %s\n", i, ev.Contract, ev.Name, formatFields(ev.Fields))
+	}
+
+	return nil
+}
+
+func (s *session) as(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(s.role)
+		return nil
+	}
+	if _, err := tx.RoleKey(args[0]); err != nil {
+		return err
+	}
+	s.role = args[0]
+
+	return nil
+}
+
+func (s *session) printLast(args []string) error {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	}
+	v, err := s.lastField(path)
+	if err != nil {
+		return err
+	}
+
+	switch v.(type) {
+	case map[string]interface{}, []*tx.Event, *tx.Quote:
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		fmt.Println(tx.FormatValue(v))
+	}
+
+	return nil
+}
+
+func (s *session) help(args []string) error {
+	fmt.Println("tx commands, they sign a tx as their own role or -as a profile and wait for send:")
+	for _, name := range sortedKeys(txOps) {
+		fmt.Printf("  %-14s -flags\n", name)
+	}
+	fmt.Println("\ncommands:")
+	for _, name := range sortedKeys(consoleCmds) {
+		c := consoleCmds[name]
+		fmt.Printf("  %-14s %-30s %s\n", name, c.args, c.short)
+	}
+	fmt.Println("  exit")
+	fmt.Printf("\n%s is the last result in args, %s.field a field of it\n", lastVar, lastVar)
+
+	return nil
+}
+
+// complete command names, contracts and methods of call, and flags of tx commands
+func (s *session) complete(line string, pos int) (head string, completions []string, tail string) {
+	before, tail := line[:pos], line[pos:]
+	start := strings.LastIndexAny(before, " \t") + 1
+	head, word := before[:start], before[start:]
+	words := strings.Fields(head)
+
+	var candidates []string
+	switch {
+	case len(words) == 0:
+		candidates = append(sortedKeys(consoleCmds), sortedKeys(txOps)...)
+		candidates = append(candidates, "exit")
+	case words[0] == "call" && len(words) == 1:
+		candidates = []string{"registry", "market", "credit"}
+	case words[0] == "call" && len(words) == 2:
+		if parsed, _, err := tx.ContractByName(words[1]); err == nil {
+			for name := range parsed.Methods {
+				candidates = append(candidates, name)
+			}
+			sort.Strings(candidates)
+		}
+	case words[0] == "as" && len(words) == 1:
+		candidates = tx.AccountNames()
+	case len(words) > 0 && words[len(words)-1] == "-as":
+		candidates = tx.AccountNames()
+	case strings.HasPrefix(word, "-"):
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		if op, ok := txOps[words[0]]; ok {
+			op.setup(fs)
+			fs.String("as", "", "")
+		} else if words[0] == "quote" {
+			orderFlags(fs, &tx.OrderSpec{}, false)
+		}
+		fs.VisitAll(func(f *flag.Flag) {
+			candidates = append(candidates, "-"+f.Name)
+		})
+	}
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			completions = append(completions, c)
+		}
+	}
+
+	return head, completions, tail
+}
+
+// split a line into words, quotes keep json args with spaces together
+func splitWords(line string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	var quote rune
+	inWord := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote %c", quote)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+
+	return words, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
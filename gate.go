@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// send on confirm and protected chains without asking, for automation
+var assumeYes bool
+
+// refused by the chain's safety settings or at the prompt, nothing was sent
+type refusedError struct {
+	error
+}
+
+// the name of a tx for the allowlist: contract.method, or the command's name
+func opName(r *Result) string {
+	if r.Call != nil {
+		return r.Call.Contract + "." + r.Call.Method
+	}
+
+	return r.Op
+}
+
+// refuse an operation the chain does not allow
+func (o *options) allow(op string) error {
+	if o.c.Allows(op) {
+		return nil
+	}
+
+	return refusedError{fmt.Errorf("%s is not allowed on chain %s, allowed ops: %s", op, o.c.Name, strings.Join(o.c.AllowedOps, ", "))}
+}
+
+// show the review and ask for confirmation as the chain's safety level wants
+func (o *options) gate(review string) error {
+	level := o.c.SafetyLevel()
+	if level == tx.SafetyOpen {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "\nchain %s is %s, review before sending:\n%s\n", o.c.Name, level, review)
+	if assumeYes {
+		fmt.Fprintln(os.Stderr, "confirmed by -yes")
+		return nil
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return refusedError{fmt.Errorf("chain %s is %s and stdin is not a terminal, pass -yes to send", o.c.Name, level)}
+	}
+
+	want := "yes"
+	if level == tx.SafetyProtected {
+		want = o.c.Name
+	}
+	fmt.Fprintf(os.Stderr, "type %q to send: ", want)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return refusedError{fmt.Errorf("no confirmation on stdin, pass -yes to send")}
+	}
+	if strings.TrimSpace(line) != want {
+		return refusedError{fmt.Errorf("not confirmed, nothing was sent")}
+	}
+
+	return nil
+}
+
+// the review of a signed tx: intent, decoded call, sender, target, value and max fee
+func (r *Result) review(txObj *tx.Tx) string {
+	signed := txObj.SignedTx
+	var b strings.Builder
+
+	if from, err := txObj.From(); err == nil && signed.To() != nil {
+		fmt.Fprintf(&b, "  intent:  %s\n", tx.Describe(from, *signed.To(), signed.Data()))
+	}
+	if r.Call != nil {
+		args, _ := json.Marshal(r.Call.Args)
+		fmt.Fprintf(&b, "  call:    %s.%s %s\n", r.Call.Contract, r.Call.Method, args)
+	}
+	fmt.Fprintf(&b, "  from:    %s\n", r.From)
+	if signed.To() != nil {
+		fmt.Fprintf(&b, "  to:      %s %s\n", tx.ContractName(*signed.To()), signed.To().Hex())
+	}
+	value := signed.Value()
+	if value == nil {
+		value = new(big.Int)
+	}
+	fmt.Fprintf(&b, "  value:   %s ETH\n", tx.FormatCredit(value, 18))
+	maxFee := new(big.Int).Mul(signed.GasPrice(), new(big.Int).SetUint64(signed.Gas()))
+	fmt.Fprintf(&b, "  max fee: %s ETH (%d gas at %s gwei)", tx.FormatCredit(maxFee, 18), signed.Gas(), tx.FormatCredit(signed.GasPrice(), 9))
+
+	return b.String()
+}
@@ -0,0 +1,50 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// used memory and used disk of the filesystem at path, in bytes
+func hostUsage(path string) (mem, disk uint64, err error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	// in kB
+	info := map[string]uint64{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[1], 10, 64)
+		if err == nil {
+			info[strings.TrimSuffix(fields[0], ":")] = n
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, 0, err
+	}
+	total, ok := info["MemTotal"]
+	avail, ok2 := info["MemAvailable"]
+	if !ok || !ok2 || avail > total {
+		return 0, 0, fmt.Errorf("/proc/meminfo has no MemTotal and MemAvailable")
+	}
+
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, fmt.Errorf("disk usage of %s: %w", path, err)
+	}
+
+	return (total - avail) * 1024, (st.Blocks - st.Bfree) * uint64(st.Bsize), nil
+}
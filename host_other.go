@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// used memory and used disk of the filesystem at path, in bytes
+func hostUsage(path string) (mem, disk uint64, err error) {
+	return 0, 0, fmt.Errorf("host usage is read on linux only")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// the default index of a chain, next to the token cache
+func indexPath(label string) string {
+	return filepath.Join(filepath.Dir(tx.TokenCachePath), "index", strings.ReplaceAll(label, "/", "-")+".db")
+}
+
+// index the events of registry, market and credit into a local store:
+// sendtx index -from 5000000 -follow
+func index(args []string) error {
+	fs := newFlagSet("index")
+	o := options{}
+	o.register(fs, false)
+	db := fs.String("db", "", "index file, default one per chain and deployment in the cache dir")
+	from := fs.Uint64("from", 0, "first block of a new index, e.g. the block the contracts were deployed at")
+	confirmations := fs.Uint64("confirmations", 6, "blocks behind the head left out of the index, a reorg deeper than them is rolled back")
+	follow := fs.Bool("follow", false, "keep indexing new blocks until interrupted")
+	interval := fs.Duration("interval", 15*time.Second, "time between syncs with -follow")
+	reset := fs.Bool("reset", false, "delete the index and build it again from -from")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+	if *db == "" {
+		*db = indexPath(o.c.Label())
+	}
+	if *reset {
+		if err := os.Remove(*db); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	ix, err := tx.OpenIndex(*db)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	total := 0
+	for {
+		cp, n, err := txObj.SyncIndex(ix, o.c.Label(), *from, *confirmations)
+		total += n
+		if err != nil && !*follow {
+			return err
+		}
+		if err != nil {
+			log.Printf("index: %v, retrying", err)
+		}
+		if !*follow {
+			height := uint64(0)
+			if cp != nil {
+				height = cp.Block
+			}
+			return emit(&Result{
+				Op:    "index",
+				Chain: o.c.Name,
+				Data:  map[string]interface{}{"db": *db, "height": height, "events": total},
+				text:  fmt.Sprintf("index %s at block %d, %d new events", *db, height, total),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// answer from the index: sendtx query cps | nodes <cp> | orders | transfers <account>
+func query(args []string) error {
+	fs := newFlagSet("query")
+	o := options{}
+	o.register(fs, false)
+	db := fs.String("db", "", "index file, default the chain's")
+	user := fs.String("user", "", "orders of this user, an address, alias or account profile")
+	provider := fs.String("provider", "", "orders with this provider, an address, alias or account profile")
+	status := fs.String("status", "", "orders whose last event contains this, e.g. created, confirm or cancel")
+	pos, err := parseFlags(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	// the index is read without the chain, only the config is needed
+	c, err := o.resolve()
+	if err != nil {
+		return err
+	}
+	if *db == "" {
+		*db = indexPath(c.Label())
+	}
+	if _, err := os.Stat(*db); err != nil {
+		return fmt.Errorf("no index of chain %s, run sendtx index first: %w", c.Label(), err)
+	}
+	ix, err := tx.OpenIndex(*db)
+	if err != nil {
+		return err
+	}
+	defer ix.Close()
+
+	account := func(i int, what string) (common.Address, error) {
+		if len(pos) <= i {
+			return common.Address{}, usageError{fmt.Errorf("query %s wants %s", pos[0], what)}
+		}
+		addr, err := tx.ResolveAddress(pos[i])
+		if err != nil {
+			return common.Address{}, usageError{err}
+		}
+		return addr, nil
+	}
+	optional := func(s string) (*common.Address, error) {
+		if s == "" {
+			return nil, nil
+		}
+		addr, err := tx.ResolveAddress(s)
+		if err != nil {
+			return nil, usageError{err}
+		}
+		return &addr, nil
+	}
+
+	var data interface{}
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	switch pos[0] {
+	case "cps":
+		cps, err := ix.CPs()
+		if err != nil {
+			return err
+		}
+		data = cps
+		fmt.Fprintln(w, "cp\tname\tfirst block\tlast block\tlast event\tevents")
+		for _, cp := range cps {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%d\n", cp.Address.Hex(), cp.Name, cp.FirstBlock, cp.LastBlock, cp.LastEvent, cp.Events)
+		}
+	case "nodes":
+		cp, err := account(1, "a cp")
+		if err != nil {
+			return err
+		}
+		nodes, err := ix.Nodes(cp)
+		if err != nil {
+			return err
+		}
+		data = nodes
+		fmt.Fprintln(w, "node\tfirst block\tlast block\tlast event\tfields")
+		for _, n := range nodes {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", n.ID, n.FirstBlock, n.LastBlock, n.LastEvent, formatFields(n.Fields))
+		}
+	case "orders":
+		u, err := optional(*user)
+		if err != nil {
+			return err
+		}
+		p, err := optional(*provider)
+		if err != nil {
+			return err
+		}
+		orders, err := ix.Orders(u, p, *status)
+		if err != nil {
+			return err
+		}
+		data = orders
+		fmt.Fprintln(w, "user\tprovider\tnode\tstatus\tfirst block\tlast block")
+		for _, o := range orders {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\n", o.User.Hex(), o.Provider.Hex(), o.NodeID, o.Status, o.FirstBlock, o.LastBlock)
+		}
+	case "transfers":
+		addr, err := account(1, "an account")
+		if err != nil {
+			return err
+		}
+		transfers, err := ix.Transfers(addr)
+		if err != nil {
+			return err
+		}
+		data = transfers
+		fmt.Fprintln(w, "block\ttx\tfields")
+		for _, e := range transfers {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", e.Block, e.TxHash.Hex(), formatFields(e.Fields))
+		}
+	default:
+		return usageError{fmt.Errorf("unknown query %q, want cps, nodes, orders or transfers", pos[0])}
+	}
+	w.Flush()
+
+	return emit(&Result{Op: "query " + pos[0], Chain: c.Name, Data: data, text: strings.TrimSuffix(b.String(), "\n")})
+}
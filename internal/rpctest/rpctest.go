@@ -0,0 +1,49 @@
+// rpctest serves a fake json-rpc chain for the tests of sendtx and tx
+package rpctest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// answers of the methods by name, to their params
+type Handlers map[string]func(params json.RawMessage) (interface{}, error)
+
+// serve the handlers until the test ends and return the url, unknown methods fail
+func Serve(t testing.TB, handlers Handlers) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		h, ok := handlers[req.Method]
+		if !ok {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "no method " + req.Method}
+		} else if result, err := h(req.Params); err != nil {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+// a handler answering result
+func Answer(result interface{}) func(json.RawMessage) (interface{}, error) {
+	return func(json.RawMessage) (interface{}, error) { return result, nil }
+}
@@ -1,237 +1,375 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/rockiecn/sendtx/tx"
+)
 
-	"github.com/grid/contracts/eth"
-	"github.com/grid/contracts/eth/contracts"
+// exit codes
+const (
+	exitOK    = 0
+	exitFail  = 1
+	exitUsage = 2
+	// refused by a local check or a preflight, nothing was sent
+	exitValidation = 3
+	// the rpc failed or could not be reached
+	exitRPC = 4
+	// the tx or call reverted
+	exitRevert = 5
 )
 
-func main() {
-	var txType uint
-	flag.UintVar(&txType, "tx", 1, "1=register 2=approve 3=createOrder 4=revise 5=userConfirm 6=userCancel 7=updatecp")
-	chain := flag.String("chain", "local", "local:local chain, sepo:sepolia test chain")
-	auto := flag.Bool("auto", false, "auto send the tx to chain")
+// a subcommand of sendtx
+type command struct {
+	name string
+	// positional args shown in the usage
+	args  string
+	short string
+	run   func(args []string) error
+}
 
-	flag.Parse()
+var commands []command
+
+func init() {
+	commands = []command{
+		{"register", "", "register the provider's cp", txOps["register"].run},
+		{"add-node", "", "add a node to the provider's cp", txOps["add-node"].run},
+		{"approve", "", "approve credit to market for an order", txOps["approve"].run},
+		{"create-order", "", "create an order on a provider's node", txOps["create-order"].run},
+		{"revise", "", "revise the provider's cp info", txOps["revise"].run},
+		{"confirm", "", "confirm the user's order with a provider", txOps["confirm"].run},
+		{"cancel", "", "cancel the user's order with a provider", txOps["cancel"].run},
+		{"update-cp", "", "update the provider's cp info and capacity", txOps["update-cp"].run},
+		{"quote", "", "print the cost of ordering a node", quote},
+		{"call", "<registry|market|credit> <method> [args...]", "call any contract method by its abi", call},
+		{"fund", "[accounts...]", "top up accounts with eth and credit from admin", fund},
+		{"deploy", "", "deploy the contracts and write the contracts file", deploy},
+		{"import", "<broadcast dir or run-latest.json>", "import the addresses a foundry script deployed into the contracts file", importBroadcast},
+		{"watch", "", "follow the events of registry, market and credit", watch},
+		{"index", "", "index the events of registry, market and credit into a local store", index},
+		{"query", "cps | nodes <cp> | orders | transfers <account>", "answer from the local index without reading the chain", query},
+		{"agent", "", "run as the provider, keeping the cp's capacity on chain up to date with updatecp", providerAgent},
+		{"compare", "cp <cp> | order <user> <provider>", "compare a cp or an order between deployments of a chain", compare},
+		{"serve", "", "build, sign and send the tx commands over http", serve},
+		{"proxy", "", "a json-rpc endpoint signing eth_sendTransaction with the account profiles", rpcProxy},
+		{"console", "", "keep one connection open and run commands at a prompt", console},
+		{"run", "<scenario.yaml>", "run a scenario of steps and print a pass/fail report", run},
+	}
+}
 
-	fmt.Println("chain: ", *chain)
+// a bad command line, exits with exitUsage
+type usageError struct {
+	error
+}
 
-	fmt.Println("type:", txType)
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sendtx <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", c.name, c.short)
+	}
+	fmt.Fprintln(os.Stderr, "\nrun sendtx <command> -h for its flags")
+	fmt.Fprintln(os.Stderr, "exit codes: 0 ok, 1 failed, 2 usage, 3 refused by validation or preflight, 4 rpc error, 5 reverted")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
 
-	var endpoint string
-	switch *chain {
-	case "local":
-		endpoint = eth.Ganache
+	name := os.Args[1]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage()
+		os.Exit(exitOK)
+	}
 
-		// load contracts
-		local := contracts.Local{}
-		err := local.LoadPath("../grid-contracts/eth/contracts/local.json")
-		//err := sepo.LoadPath("../grid-contracts/script/deployment.json")
-		if err != nil {
-			panic(err)
+	for _, c := range commands {
+		if c.name != name {
+			continue
 		}
 
-		tx.Contracts = local.Contracts
-
-		fmt.Println("contract addresses on ganache:", tx.Contracts)
-	case "sepo":
-		endpoint = eth.Sepolia
+		err := c.run(os.Args[2:])
+		if err == nil {
+			os.Exit(exitOK)
+		}
 
-		// load contracts
-		sepo := contracts.Sepo{}
-		err := sepo.LoadPath("../grid-contracts/eth/contracts/sepo.json")
-		//err := sepo.LoadPath("../grid-contracts/script/deployment.json")
-		if err != nil {
-			panic(err)
+		// one result per operation, a failed one too
+		if structured() && !emitted {
+			emit(&Result{Op: name, Error: errorInfo(err)})
 		}
 
-		tx.Contracts = sepo.Contracts
+		code := exitCode(err)
+		if code == exitUsage {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			log.Println(err)
+		}
+		os.Exit(code)
+	}
 
-		fmt.Println("contract addresses on sepo:", tx.Contracts)
-	case "dev":
-		endpoint = eth.DevChain
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	os.Exit(exitUsage)
+}
 
-		// load contracts
-		dev := contracts.Dev{}
-		err := dev.LoadPath("../grid-contracts/eth/contracts/dev.json")
-		//err := dev.LoadPath("../grid-contracts/script/deployment.json")
-		if err != nil {
-			panic(err)
+// a flag set printing the usage line of the command
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				line := c.name
+				if c.args != "" {
+					line += " " + c.args
+				}
+				fmt.Fprintf(fs.Output(), "usage: sendtx %s [flags]\n\n%s\n\nflags:\n", line, c.short)
+			}
 		}
+		fs.PrintDefaults()
+	}
 
-		tx.Contracts = dev.Contracts
+	return fs
+}
 
-		fmt.Println("contract addresses on dev:", tx.Contracts)
-	case "test":
-		endpoint = eth.TestChain
+// parse flags mixed with positional args, everything after -- is positional
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return pos, nil
+		}
 
-		// load contracts
-		test := contracts.Test{}
-		err := test.LoadPath("../grid-contracts/eth/contracts/test.json")
-		//err := dev.LoadPath("../grid-contracts/script/deployment.json")
-		if err != nil {
-			panic(err)
+		// the flag set stopped at a --
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(pos, rest...), nil
 		}
 
-		tx.Contracts = test.Contracts
+		pos = append(pos, rest[0])
+		args = rest[1:]
+	}
+}
 
-		fmt.Println("contract addresses on test:", tx.Contracts)
+// parse the flags of a command, -h is not an error
+func parseFlags(fs *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	pos, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitOK)
+	}
+	if err != nil {
+		return nil, usageError{err}
 	}
 
-	txObj := tx.NewTx(endpoint)
+	if len(pos) < minArgs || (maxArgs >= 0 && len(pos) > maxArgs) {
+		fs.Usage()
+		return nil, usageError{fmt.Errorf("%s: wrong number of args: %q", fs.Name(), pos)}
+	}
 
-	switch txType {
-	case 1:
-		// signed register tx for send to chain directly
-		err := txObj.MakeRegisterTx()
-		if err != nil {
-			log.Fatal(err)
-		}
+	return pos, nil
+}
 
-		log.Printf("signedTx for [registcp]: \n%s\n", txObj.JsonTx)
+// flags shared by commands
+type options struct {
+	chain  string
+	config string
+	rpc    string
+	// named deployment of the chain's contracts
+	deployment string
+	// contract address overrides
+	credit, registry, market string
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+	auto bool
 
-		log.Printf("add 2 nodes for this cp")
+	// account profiles file, and the profile acting in the op's role
+	accounts string
+	as       string
 
-		// add node1
-		node1, err := tx.NewNode()
-		if err != nil {
-			panic(err)
-		}
-		err = txObj.MakeAddNodeTx(node1)
-		if err != nil {
-			log.Fatal(err)
-		}
+	// the chain in use, set by resolve
+	c *tx.Chain
+}
 
-		log.Printf("signedTx for [add node 1]: \n%s\n", txObj.JsonTx)
+// add the chain flags, and the send flags for commands making a tx
+func (o *options) register(fs *flag.FlagSet, sends bool) {
+	fs.StringVar(&o.chain, "chain", "local", "chain name from the chains config: local, sepo, dev, test or your own")
+	fs.StringVar(&o.config, "config", tx.ChainsPath, "chains config file")
+	fs.StringVar(&o.rpc, "rpc", "", "rpc url, overrides the chain's")
+	fs.StringVar(&o.deployment, "deployment", "", "named deployment of the chain's contracts, e.g. v1 or v2, the chain's default when empty")
+	fs.StringVar(&o.credit, "credit-addr", "", "credit contract address, overrides the chain's")
+	fs.StringVar(&o.registry, "registry-addr", "", "registry contract address, overrides the chain's")
+	fs.StringVar(&o.market, "market-addr", "", "market contract address, overrides the chain's")
+	fs.StringVar(&o.accounts, "accounts", tx.AccountsPath, "account profiles file")
+	fs.StringVar(&outputMode, "output", outText, "result format on stdout: text, json, ndjson or table, diagnostics go to stderr")
+	fs.BoolVar(&assumeYes, "yes", false, "send on confirm and protected chains without asking, for automation")
+	if !sends {
+		return
+	}
 
-		// send tx
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+	fs.BoolVar(&o.auto, "auto", false, "auto send the tx to chain")
+	fs.BoolVar(&tx.Strict, "strict", false, "fail on validation warnings, e.g. a payload for an unconfigured provider")
+	fs.BoolVar(&tx.Preflight, "preflight", true, "check chain state before signing and refuse txs bound to fail")
+	fs.BoolVar(&tx.DryRun, "dry-run", false, "simulate the tx and print what it does and costs, without signing or sending it")
+}
 
-		// add node2
-		node2, err := tx.NewNode2()
-		if err != nil {
-			panic(err)
-		}
-		err = txObj.MakeAddNodeTx(node2)
-		if err != nil {
-			log.Fatal(err)
-		}
+// add -as, the account profile acting in the command's role
+func (o *options) asFlag(fs *flag.FlagSet, role string) {
+	fs.StringVar(&o.as, "as", "", "account profile signing as the "+role+", e.g. alice or provider-3, the default "+role+" when empty")
+}
 
-		log.Printf("signedTx for [add node 2]: \n%s\n", txObj.JsonTx)
+// sign the ops of role with the -as profile, until restore is called
+func (o *options) act(role string) (restore func(), err error) {
+	if o.as == "" {
+		return func() {}, nil
+	}
 
-		// send tx
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+	restore, err = tx.UseAccount(o.as, role)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	log.Printf("acting as %s: %s", role, o.as)
 
-	case 2:
-		// tx for send to chain directly
-		err := txObj.MakeApproveTx()
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("signedTx for [approve] credit to market: \n%s\n", txObj.JsonTx)
+	return restore, nil
+}
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-	case 3:
-		// signed market.createorder tx for send to chain directly
-		err := txObj.MakeCreateOrderTx()
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("signedTx for [createorder]: \n%s\n", txObj.JsonTx)
+// find the chain in the config with its rpc override, before a deployment is selected
+func (o *options) baseChain() (*tx.Chain, error) {
+	if err := checkOutput(); err != nil {
+		return nil, err
+	}
+	if err := tx.LoadAccounts(o.accounts); err != nil {
+		return nil, err
+	}
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-	case 4:
-		// signed registry.revise tx for send to chain directly
-		err := txObj.MakeReviseTx()
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("signedTx for [revise]: \n%s\n", txObj.JsonTx)
+	chains, err := tx.LoadChains(o.config)
+	if err != nil {
+		return nil, err
+	}
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+	c, ok := chains[o.chain]
+	if !ok {
+		return nil, usageError{fmt.Errorf("unknown chain %q, want one of %v", o.chain, tx.ChainNames(chains))}
+	}
 
-	case 5:
-		// signed market.userconfirm tx for send to chain directly
-		err := txObj.MakeUserConfirmTx()
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("signedTx for [userconfirm]: \n%s\n", txObj.JsonTx)
+	if o.rpc != "" {
+		c.RPC = o.rpc
+	}
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+	return c, nil
+}
 
-	case 6:
-		// signed market.userconfirm tx for send to chain directly
-		err := txObj.MakeUserCancelTx()
-		if err != nil {
-			log.Fatal(err)
+// find the chain in the config, select its deployment and apply the overrides
+func (o *options) resolve() (*tx.Chain, error) {
+	base, err := o.baseChain()
+	if err != nil {
+		return nil, err
+	}
+	c, err := base.Select(o.deployment)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	for _, a := range []string{o.credit, o.registry, o.market} {
+		if a != "" && !common.IsHexAddress(a) {
+			return nil, usageError{fmt.Errorf("invalid contract address %q", a)}
 		}
-		log.Printf("signedTx for [usercancel]: \n%s\n", txObj.JsonTx)
+	}
+	if o.credit != "" {
+		c.Contracts.Credit = o.credit
+	}
+	if o.registry != "" {
+		c.Contracts.Registry = o.registry
+	}
+	if o.market != "" {
+		c.Contracts.Market = o.market
+	}
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
+	o.c = c
 
-	// update cp info
-	case 7:
-		err := txObj.MakeUpdateCPTx()
-		if err != nil {
-			log.Fatal(err)
-		}
+	return c, nil
+}
 
-		log.Printf("signedTx for [updatecp]: \n%s\n", txObj.JsonTx)
+// connect to the chain with its contracts and credit token loaded
+func (o *options) connect() (*tx.Tx, error) {
+	c, err := o.resolve()
+	if err != nil {
+		return nil, err
+	}
 
-		if *auto {
-			err := txObj.Send()
-			if err != nil {
-				log.Fatal(err)
-			}
+	if err := c.Use(); err != nil {
+		return nil, err
+	}
+	log.Printf("contract addresses on %s: %v", c.Label(), tx.Contracts)
+
+	txObj := tx.NewTx(c.RPC)
+
+	// refuse a wrong network or a stale address file before anything is signed
+	if err := txObj.CheckChain(c); err != nil {
+		return nil, err
+	}
+
+	// credit decimals and symbol for reading and printing amounts
+	if err := txObj.LoadCredit(); err != nil {
+		return nil, err
+	}
+
+	return txObj, nil
+}
+
+// print the signed tx, and send it with -auto, or only simulate it with -dry-run
+func (o *options) finish(txObj *tx.Tx, name string) error {
+	if tx.DryRun {
+		return o.dryRun(txObj, name)
+	}
+
+	r := o.txResult(txObj, name)
+	if err := o.allow(opName(r)); err != nil {
+		return err
+	}
+	if !o.auto {
+		return emit(r)
+	}
+	if err := o.gate(r.review(txObj)); err != nil {
+		return err
+	}
+
+	err := txObj.Send()
+	if err == nil || tx.IsRevert(err) {
+		if rerr := o.addReceipt(r, txObj); rerr != nil && err == nil {
+			err = rerr
 		}
+	}
+	if err != nil {
+		r.Error = errorInfo(err)
+		emit(r)
+		return err
+	}
 
+	return emit(r)
+}
+
+// simulate the unsigned tx and print its summary, cost and outcome
+func (o *options) dryRun(txObj *tx.Tx, name string) error {
+	sim, err := txObj.Simulate()
+	if err != nil {
+		return err
+	}
+
+	r := &Result{Op: name, Chain: o.c.Name, From: sim.From.Hex(), Data: sim, text: sim.String()}
+	if !sim.Reverted {
+		return emit(r)
 	}
+
+	err = fmt.Errorf("simulation of %s reverted: %s", name, sim.Reason)
+	r.Error = errorInfo(err)
+	emit(r)
+
+	return err
 }
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// output modes of results on stdout, diagnostics always go to stderr
+const (
+	outText   = "text"
+	outJSON   = "json"
+	outNDJSON = "ndjson"
+	outTable  = "table"
+)
+
+var outputMode = outText
+
+// a result was printed for the command, a failure needs no extra one
+var emitted bool
+
+// the result of one operation, printed in the output mode
+type Result struct {
+	Op    string `json:"op"`
+	Chain string `json:"chain,omitempty"`
+
+	// a signed tx
+	From  string          `json:"from,omitempty"`
+	Hash  string          `json:"hash,omitempty"`
+	RawTx string          `json:"rawTx,omitempty"`
+	Tx    json.RawMessage `json:"tx,omitempty"`
+	Call  *CallInfo       `json:"call,omitempty"`
+	// what the tx does in plain language
+	Intent string `json:"intent,omitempty"`
+	// the tx without its signature and the hash signed for it, given by serve
+	Unsigned json.RawMessage `json:"unsigned,omitempty"`
+	SigHash  string          `json:"sigHash,omitempty"`
+
+	// the tx once sent
+	Sent     bool         `json:"sent"`
+	Receipt  *ReceiptInfo `json:"receipt,omitempty"`
+	Events   []*tx.Event  `json:"events,omitempty"`
+	Explorer string       `json:"explorer,omitempty"`
+
+	// outputs of a view call
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+	// result of other commands, e.g. a quote or balances
+	Data interface{} `json:"data,omitempty"`
+
+	Error *ErrorInfo `json:"error,omitempty"`
+
+	// text of the text mode, derived from the fields when empty
+	text string
+}
+
+// the decoded method call of a tx
+type CallInfo struct {
+	Contract string                 `json:"contract"`
+	Method   string                 `json:"method"`
+	Args     map[string]interface{} `json:"args"`
+}
+
+// what a sent tx cost, amounts in wei
+type ReceiptInfo struct {
+	Status   uint64 `json:"status"`
+	Block    uint64 `json:"block"`
+	GasUsed  uint64 `json:"gasUsed"`
+	GasPrice string `json:"gasPrice"`
+	GasCost  string `json:"gasCost"`
+}
+
+// a failed operation, kind is usage, validation, rpc, revert or error,
+// validation includes ops refused by the chain's safety settings
+type ErrorInfo struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// refuse an unknown output mode
+func checkOutput() error {
+	switch outputMode {
+	case outText, outJSON, outNDJSON, outTable:
+		return nil
+	}
+
+	return usageError{fmt.Errorf("unknown output %q, want text, json, ndjson or table", outputMode)}
+}
+
+// is the output meant for other tools
+func structured() bool {
+	return outputMode != outText
+}
+
+// print a result on stdout
+func emit(r *Result) error {
+	emitted = true
+
+	switch outputMode {
+	case outJSON:
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case outNDJSON:
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case outTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, row := range r.rows() {
+			fmt.Fprintf(w, "%s\t%s\n", row[0], row[1])
+		}
+		return w.Flush()
+	default:
+		if r.text != "" {
+			fmt.Println(r.text)
+			return nil
+		}
+		fmt.Print(r.txText())
+	}
+
+	return nil
+}
+
+// the result of a signed tx, not sent yet
+func (o *options) txResult(txObj *tx.Tx, op string) *Result {
+	signed := txObj.SignedTx
+	r := &Result{
+		Op:   op,
+		Hash: signed.Hash().Hex(),
+		Tx:   json.RawMessage(txObj.JsonTx),
+	}
+	if o.c != nil {
+		r.Chain = o.c.Name
+	}
+	if raw, err := signed.MarshalBinary(); err == nil {
+		r.RawTx = hexutil.Encode(raw)
+	}
+	if from, err := txObj.From(); err == nil {
+		r.From = from.Hex()
+	}
+	if signed.To() != nil {
+		if contract, m, args, err := tx.DecodeCall(*signed.To(), signed.Data()); err == nil {
+			r.Call = &CallInfo{contract, m.Name, tx.JSONValues(tx.OutputFields(m.Inputs, args))}
+		}
+		if from, err := txObj.From(); err == nil {
+			r.Intent = tx.Describe(from, *signed.To(), signed.Data())
+		}
+	}
+
+	return r
+}
+
+// add the receipt and events of the sent tx
+func (o *options) addReceipt(r *Result, txObj *tx.Tx) error {
+	r.Sent = true
+	if o.c != nil {
+		r.Explorer = o.c.TxURL(r.Hash)
+	}
+
+	receipt, err := txObj.Receipt()
+	if err != nil {
+		return err
+	}
+
+	price := receipt.EffectiveGasPrice
+	if price == nil {
+		price = txObj.SignedTx.GasPrice()
+	}
+	cost := new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed))
+	r.Receipt = &ReceiptInfo{
+		Status:   receipt.Status,
+		Block:    receipt.BlockNumber.Uint64(),
+		GasUsed:  receipt.GasUsed,
+		GasPrice: price.String(),
+		GasCost:  cost.String(),
+	}
+	r.Events = tx.DecodeLogs(receipt.Logs)
+
+	return nil
+}
+
+// text of a tx result
+func (r *Result) txText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "signedTx for [%s]: \n%s\n", r.Op, r.Tx)
+
+	if rc := r.Receipt; rc != nil {
+		cost, _ := new(big.Int).SetString(rc.GasCost, 10)
+		fmt.Fprintf(&b, "tx %s in block %d, gas used %d, cost %s ETH\n", r.Hash, rc.Block, rc.GasUsed, tx.FormatCredit(cost, 18))
+	}
+	if r.Explorer != "" {
+		fmt.Fprintf(&b, "explorer: %s\n", r.Explorer)
+	}
+	for i, ev := range r.Events {
+		fmt.Fprintf(&b, "event %d %s.%s %s\n", i, ev.Contract, ev.Name, formatFields(ev.Fields))
+	}
+	if r.Error != nil {
+		fmt.Fprintf(&b, "%s error: %s\n", r.Error.Kind, r.Error.Message)
+	}
+
+	return b.String()
+}
+
+// the result as rows of a two column table
+func (r *Result) rows() [][2]string {
+	var rows [][2]string
+	add := func(k, v string) {
+		if v != "" {
+			rows = append(rows, [2]string{k, v})
+		}
+	}
+
+	add("op", r.Op)
+	add("chain", r.Chain)
+	add("from", r.From)
+	add("hash", r.Hash)
+	if r.Call != nil {
+		add("call", r.Call.Contract+"."+r.Call.Method)
+		flatten("args", r.Call.Args, add)
+	}
+	add("intent", r.Intent)
+	if r.Hash != "" {
+		add("sent", strconv.FormatBool(r.Sent))
+	}
+	if rc := r.Receipt; rc != nil {
+		add("status", strconv.FormatUint(rc.Status, 10))
+		add("block", strconv.FormatUint(rc.Block, 10))
+		add("gasUsed", strconv.FormatUint(rc.GasUsed, 10))
+		add("gasPrice", rc.GasPrice)
+		add("gasCost", rc.GasCost)
+	}
+	add("explorer", r.Explorer)
+	for i, ev := range r.Events {
+		add(fmt.Sprintf("event.%d", i), ev.Contract+"."+ev.Name+" "+formatFields(ev.Fields))
+	}
+	flatten("out", r.Outputs, add)
+	flatten("data", r.Data, add)
+	if r.Error != nil {
+		add("error", r.Error.Kind+": "+r.Error.Message)
+	}
+
+	return rows
+}
+
+// add the leaves of v as dotted keys, through its json form
+func flatten(prefix string, v interface{}, add func(k, v string)) {
+	if v == nil {
+		return
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		add(prefix, fmt.Sprint(v))
+		return
+	}
+	var generic interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&generic); err != nil {
+		add(prefix, string(b))
+		return
+	}
+
+	var walk func(k string, v interface{})
+	walk = func(k string, v interface{}) {
+		switch e := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(e))
+			for key := range e {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(k+"."+key, e[key])
+			}
+		case []interface{}:
+			for i, item := range e {
+				walk(k+"."+strconv.Itoa(i), item)
+			}
+		case nil:
+		default:
+			add(k, fmt.Sprint(e))
+		}
+	}
+	walk(prefix, generic)
+}
+
+// the kind of a failure, for the error object and the exit code
+func errorKind(err error) string {
+	var ue usageError
+	var re refusedError
+	switch {
+	case errors.As(err, &ue):
+		return "usage"
+	case errors.As(err, &re), tx.IsValidation(err):
+		return "validation"
+	case tx.IsRevert(err):
+		return "revert"
+	case isRPC(err):
+		return "rpc"
+	}
+
+	return "error"
+}
+
+// did the rpc fail, rather than the operation
+func isRPC(err error) bool {
+	var rpcErr rpc.Error
+	var httpErr rpc.HTTPError
+	var urlErr *url.Error
+	var netErr net.Error
+
+	return errors.As(err, &rpcErr) || errors.As(err, &httpErr) || errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+func errorInfo(err error) *ErrorInfo {
+	return &ErrorInfo{Kind: errorKind(err), Message: err.Error()}
+}
+
+// exit code of a failure by its kind
+func exitCode(err error) int {
+	switch errorKind(err) {
+	case "usage":
+		return exitUsage
+	case "validation":
+		return exitValidation
+	case "revert":
+		return exitRevert
+	case "rpc":
+		return exitRPC
+	}
+
+	return exitFail
+}
+
+// fields as one line of json, values formatted like the outputs of call
+func formatFields(fields map[string]interface{}) string {
+	vals := make(map[string]string, len(fields))
+	for k, v := range fields {
+		vals[k] = tx.FormatValue(v)
+	}
+
+	b, err := json.Marshal(vals)
+	if err != nil {
+		return fmt.Sprint(fields)
+	}
+
+	return string(b)
+}
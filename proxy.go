@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// default config file of the proxy policy, see proxy.example.json
+const policyPath = "proxy.json"
+
+// json-rpc error codes
+const (
+	rpcInvalidRequest = -32600
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// a json-rpc request or response
+type rpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// a signing account of the proxy and the calls it may sign
+type proxySigner struct {
+	account *tx.Account
+	addr    common.Address
+	sk      string
+	// calls like market.createOrder or market.*, an address with a selector like 0xAb..12.0xa9059cbb,
+	// a plain address for transfers, or create, matched like path.Match
+	allow []string
+}
+
+type proxy struct {
+	o        *options
+	txObj    *tx.Tx
+	upstream string
+	signers  map[common.Address]*proxySigner
+	// clients and the json-rpc methods they may call, nil on a loopback address without -tokens
+	tokens map[string]*serveToken
+
+	// sign and submit one tx at a time, so the nonces of a sender are sent in order
+	mu sync.Mutex
+}
+
+// the methods answered by the proxy, all others go upstream
+var proxyMethods = map[string]func(p *proxy, params json.RawMessage) (interface{}, error){
+	"eth_accounts":        (*proxy).accounts,
+	"eth_sendTransaction": (*proxy).sendTransaction,
+	"eth_signTransaction": (*proxy).signTransaction,
+}
+
+// a json-rpc endpoint signing eth_sendTransaction and eth_signTransaction with the account profiles,
+// and forwarding everything else to the chain's rpc
+func rpcProxy(args []string) error {
+	fs := newFlagSet("proxy")
+	o := options{}
+	o.register(fs, false)
+	listen := fs.String("listen", "127.0.0.1:8646", "address to listen on")
+	policy := fs.String("policy", policyPath, "the accounts the proxy signs for and the calls each may sign")
+	tokens := fs.String("tokens", "", "auth tokens of the clients like serve's, their endpoints are json-rpc methods, required off loopback")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if structured() {
+		return usageError{fmt.Errorf("proxy answers json-rpc, -output is not for it")}
+	}
+	// anyone reaching the proxy signs with its accounts
+	if *tokens == "" && !loopback(*listen) {
+		return usageError{fmt.Errorf("proxy on %s is not on loopback, give its clients -tokens", *listen)}
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(o.c.RPC, "http://") && !strings.HasPrefix(o.c.RPC, "https://") {
+		return usageError{fmt.Errorf("proxy forwards over http, rpc %s is not", o.c.RPC)}
+	}
+
+	p := &proxy{o: &o, txObj: txObj, upstream: o.c.RPC}
+	if p.signers, err = loadPolicy(*policy); err != nil {
+		return err
+	}
+	if *tokens != "" {
+		if p.tokens, err = loadTokens(*tokens); err != nil {
+			return err
+		}
+	}
+	for addr, s := range p.signers {
+		log.Printf("proxy signs for %s %s: %s", s.account.Name, addr.Hex(), strings.Join(s.allow, ", "))
+	}
+	if level := o.c.SafetyLevel(); level != tx.SafetyOpen && !assumeYes {
+		log.Printf("chain %s is %s, eth_sendTransaction is refused without -yes", o.c.Name, level)
+	}
+
+	log.Printf("proxy for chain %s on http://%s, upstream %s", o.c.Label(), *listen, p.upstream)
+
+	return http.ListenAndServe(*listen, p)
+}
+
+// is the listen address only reachable from this host
+func loopback(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// read the policy, accounts not in it are not signed for
+func loadPolicy(path string) (map[common.Address]*proxySigner, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("proxy needs its policy: %w", err)
+	}
+
+	var cfg struct {
+		// calls each account may sign, by account profile name
+		Accounts map[string][]string `json:"accounts"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(cfg.Accounts) == 0 {
+		return nil, fmt.Errorf("%s: no accounts", path)
+	}
+
+	signers := map[common.Address]*proxySigner{}
+	for name, allow := range cfg.Accounts {
+		a, err := tx.GetAccount(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// keys are read now, not while serving
+		sk, err := a.SK()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		addr, _ := a.Addr()
+		for _, pattern := range allow {
+			if _, err := pathpkg.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: account %s has a bad call %q: %w", path, name, pattern, err)
+			}
+		}
+		if other, ok := signers[addr]; ok {
+			return nil, fmt.Errorf("%s: accounts %s and %s are both %s", path, other.account.Name, name, addr.Hex())
+		}
+		signers[addr] = &proxySigner{a, addr, sk, allow}
+	}
+
+	return signers, nil
+}
+
+// answer a request or a batch, forwarding it whole when none of it is signed here
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "json-rpc wants POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	batch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	var msgs []*rpcMessage
+	if batch {
+		err = json.Unmarshal(body, &msgs)
+	} else {
+		msg := &rpcMessage{}
+		err = json.Unmarshal(body, msg)
+		msgs = []*rpcMessage{msg}
+	}
+	if err != nil {
+		writeRPC(w, &rpcMessage{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcInvalidRequest, Message: err.Error()}})
+		return
+	}
+
+	local := false
+	methods := make([]string, len(msgs))
+	for i, m := range msgs {
+		if _, ok := proxyMethods[m.Method]; ok {
+			local = true
+		}
+		methods[i] = m.Method
+	}
+	if p.tokens != nil {
+		// every method of a batch must be allowed, reads too
+		client, status := auth(p.tokens, r, methods...)
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if local {
+			log.Printf("proxy: %s from %s", strings.Join(methods, ", "), client)
+		}
+	}
+	if !local {
+		p.pass(w, body)
+		return
+	}
+
+	replies := make([]*rpcMessage, len(msgs))
+	for i, m := range msgs {
+		replies[i] = p.answer(m)
+	}
+	if batch {
+		writeRPC(w, replies)
+		return
+	}
+	writeRPC(w, replies[0])
+}
+
+// answer one request, here or upstream
+func (p *proxy) answer(m *rpcMessage) *rpcMessage {
+	reply := &rpcMessage{Version: "2.0", ID: m.ID}
+
+	h, ok := proxyMethods[m.Method]
+	if !ok {
+		b, err := json.Marshal(m)
+		if err == nil {
+			b, err = p.forward(b)
+		}
+		if err == nil {
+			err = json.Unmarshal(b, reply)
+		}
+		if err != nil {
+			reply.Error = &rpcError{Code: rpcServerError, Message: "upstream: " + err.Error()}
+		}
+		return reply
+	}
+
+	result, err := h(p, m.Params)
+	if err != nil {
+		code := rpcServerError
+		if _, bad := err.(usageError); bad {
+			code = rpcInvalidParams
+		}
+		reply.Error = &rpcError{Code: code, Message: err.Error()}
+		return reply
+	}
+	if reply.Result, err = json.Marshal(result); err != nil {
+		reply.Error = &rpcError{Code: rpcServerError, Message: err.Error()}
+	}
+
+	return reply
+}
+
+// post the body upstream and return its answer
+func (p *proxy) forward(body []byte) ([]byte, error) {
+	resp, err := http.Post(p.upstream, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// copy the upstream answer of the body as it is
+func (p *proxy) pass(w http.ResponseWriter, body []byte) {
+	resp, err := http.Post(p.upstream, "application/json", bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "upstream: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func writeRPC(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// the addresses signed for
+func (p *proxy) accounts(params json.RawMessage) (interface{}, error) {
+	addrs := make([]common.Address, 0, len(p.signers))
+	for addr := range p.signers {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Hex() < addrs[j].Hex() })
+
+	return addrs, nil
+}
+
+// the tx object of the params, its signer and the call checked against the policy and the chain
+func (p *proxy) txArgs(params json.RawMessage) (*tx.TxArgs, *proxySigner, string, error) {
+	var args []*tx.TxArgs
+	if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 || args[0] == nil {
+		return nil, nil, "", usageError{fmt.Errorf("want one tx object as params")}
+	}
+	a := args[0]
+	if a.From == nil {
+		return nil, nil, "", usageError{fmt.Errorf("from is missing")}
+	}
+	s, ok := p.signers[*a.From]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("no account %s to sign with", a.From.Hex())
+	}
+
+	data, err := a.CallData()
+	if err != nil {
+		return nil, nil, "", usageError{err}
+	}
+	call := tx.CallName(a.To, data)
+	allowed := false
+	for _, pattern := range s.allow {
+		if match, _ := pathpkg.Match(pattern, call); match {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, nil, "", fmt.Errorf("account %s may not call %s", s.account.Name, call)
+	}
+	if err := p.o.allow(call); err != nil {
+		return nil, nil, "", err
+	}
+
+	return a, s, call, nil
+}
+
+// sign and send a tx, answering its hash
+func (p *proxy) sendTransaction(params json.RawMessage) (interface{}, error) {
+	args, s, call, err := p.txArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	if level := p.o.c.SafetyLevel(); level != tx.SafetyOpen && !assumeYes {
+		return nil, fmt.Errorf("chain %s is %s, start the proxy with -yes to send", p.o.c.Name, level)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	signed, err := p.txObj.SignArgs(s.sk, args)
+	if err != nil {
+		return nil, err
+	}
+	p.logTx("sending", s, call, signed)
+
+	sender := p.txObj.Fork()
+	sender.SignedTx = signed
+	if err := sender.Submit(); err != nil {
+		return nil, err
+	}
+
+	return signed.Hash(), nil
+}
+
+// sign a tx without sending it, answering it raw and decoded
+func (p *proxy) signTransaction(params json.RawMessage) (interface{}, error) {
+	args, s, call, err := p.txArgs(params)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := p.txObj.SignArgs(s.sk, args)
+	if err != nil {
+		return nil, err
+	}
+	p.logTx("signed", s, call, signed)
+
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"raw": hexutil.Bytes(raw), "tx": signed}, nil
+}
+
+func (p *proxy) logTx(what string, s *proxySigner, call string, signed *types.Transaction) {
+	intent := call
+	if signed.To() != nil {
+		intent = tx.Describe(s.addr, *signed.To(), signed.Data())
+	}
+	log.Printf("proxy: %s %s nonce %d for %s: %s", what, signed.Hash().Hex(), signed.Nonce(), s.account.Name, intent)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rockiecn/sendtx/internal/rpctest"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+func TestLoopback(t *testing.T) {
+	for listen, want := range map[string]bool{
+		"127.0.0.1:8646": true,
+		"localhost:8646": true,
+		"[::1]:8646":     true,
+		":8646":          false,
+		"0.0.0.0:8646":   false,
+		"10.0.0.5:8646":  false,
+		"example:8646":   false,
+		"8646":           false,
+	} {
+		if got := loopback(listen); got != want {
+			t.Errorf("loopback(%q) = %v, want %v", listen, got, want)
+		}
+	}
+}
+
+// post a json-rpc body to the proxy with the bearer token
+func postRPC(p *proxy, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestProxyAuth(t *testing.T) {
+	p := &proxy{
+		signers: map[common.Address]*proxySigner{},
+		tokens: map[string]*serveToken{
+			"wallet": {Endpoints: []string{"eth_accounts"}, value: "read"},
+			"ops":    {Endpoints: []string{"*"}, value: "all"},
+		},
+	}
+	accounts := `{"jsonrpc":"2.0","id":1,"method":"eth_accounts"}`
+	send := `[{"jsonrpc":"2.0","id":1,"method":"eth_accounts"},{"jsonrpc":"2.0","id":2,"method":"eth_sendTransaction","params":[]}]`
+
+	for _, tc := range []struct {
+		name, token, body string
+		status            int
+	}{
+		{"no token", "", accounts, http.StatusUnauthorized},
+		{"unknown token", "nope", accounts, http.StatusUnauthorized},
+		{"allowed method", "read", accounts, http.StatusOK},
+		{"batch with a method not allowed", "read", send, http.StatusForbidden},
+		{"all methods", "all", send, http.StatusOK},
+	} {
+		if w := postRPC(p, tc.token, tc.body); w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.status, w.Body)
+		}
+	}
+}
+
+// a tx refused while signing keeps the nonces reserved by the txs sent before it
+func TestProxySendKeepsNonces(t *testing.T) {
+	saved := tx.Contracts
+	defer func() { tx.Contracts = saved }()
+
+	c := testChain(t)
+	if err := c.Use(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := common.HexToAddress(tx.U_ADDR)
+	defer tx.Nonces.Forget(user)
+	p := &proxy{
+		o: &options{c: c},
+		txObj: tx.NewTx(rpctest.Serve(t, rpctest.Handlers{
+			"eth_chainId":             func(json.RawMessage) (interface{}, error) { return "0x539", nil },
+			"eth_gasPrice":            func(json.RawMessage) (interface{}, error) { return "0x3b9aca00", nil },
+			"eth_getTransactionCount": func(json.RawMessage) (interface{}, error) { return "0x7", nil },
+			"eth_sendRawTransaction":  func(json.RawMessage) (interface{}, error) { return common.Hash{}, nil },
+		})),
+		signers: map[common.Address]*proxySigner{
+			user: {&tx.Account{Name: "user"}, user, tx.U_SK, []string{"*"}},
+		},
+	}
+	call := func(method, extra string) *rpcMessage {
+		t.Helper()
+		body := `{"jsonrpc":"2.0","id":1,"method":"` + method + `","params":[{"from":"` + user.Hex() +
+			`","to":"0x00000000000000000000000000000000000000aa","gas":"0x5208"` + extra + `}]}`
+		reply := &rpcMessage{}
+		if err := json.NewDecoder(postRPC(p, "", body).Body).Decode(reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	if reply := call("eth_sendTransaction", ""); reply.Error != nil {
+		t.Fatalf("send: %s", reply.Error.Message)
+	}
+	if reply := call("eth_sendTransaction", `,"chainId":"0x1"`); reply.Error == nil {
+		t.Fatal("sent a tx for chain 1")
+	}
+
+	reply := call("eth_signTransaction", "")
+	if reply.Error != nil {
+		t.Fatalf("sign: %s", reply.Error.Message)
+	}
+	var signed struct {
+		Tx struct {
+			Nonce string `json:"nonce"`
+		} `json:"tx"`
+	}
+	if err := json.Unmarshal(reply.Result, &signed); err != nil {
+		t.Fatal(err)
+	}
+	if signed.Tx.Nonce != "0x8" {
+		t.Errorf("next nonce %s, want 0x8 after the sent 0x7", signed.Tx.Nonce)
+	}
+}
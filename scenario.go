@@ -0,0 +1,588 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rockiecn/sendtx/tx"
+	"gopkg.in/yaml.v3"
+)
+
+// a scenario file, see scenario.example.yaml
+type Scenario struct {
+	Name string `yaml:"name"`
+	// run the next steps after a failed one, unless the step says otherwise
+	ContinueOnFailure bool              `yaml:"continueOnFailure"`
+	Vars              map[string]string `yaml:"vars"`
+	Steps             []Step            `yaml:"steps"`
+
+	// refuse ops the chain does not allow, nil to allow all
+	allow func(op string) error
+}
+
+// one operation of a scenario
+type Step struct {
+	Name string `yaml:"name"`
+	// a tx command like create-order, or call, fund, wait
+	Op string `yaml:"op"`
+	// role or account profile signing a call, or account profile signing the op as its role
+	As string `yaml:"as"`
+	// flags of the op without the dash, e.g. deposit: 40
+	Params map[string]string `yaml:"params"`
+	// positional args of call and fund
+	Args []string `yaml:"args"`
+	// variables set from the step's result, e.g. nodeId: event.AddNode.id
+	Capture map[string]string `yaml:"capture"`
+	Assert  []Assertion       `yaml:"assert"`
+
+	ContinueOnFailure *bool `yaml:"continueOnFailure"`
+}
+
+// a check after a step: an event it emitted, or a view of chain state
+type Assertion struct {
+	// name of an event in the step's receipt, with the fields it must have
+	Event  string            `yaml:"event"`
+	Fields map[string]string `yaml:"fields"`
+
+	// a view call: contract, method and args, its output at field must equal
+	Call   []string `yaml:"call"`
+	As     string   `yaml:"as"`
+	Field  string   `yaml:"field"`
+	Equals string   `yaml:"equals"`
+}
+
+// what a step left behind for captures and assertions
+type stepResult struct {
+	receipt *types.Receipt
+	events  []*tx.Event
+	// outputs of a view call by name
+	out map[string]interface{}
+}
+
+// outcome of a step in the report
+type stepReport struct {
+	name   string
+	status string
+	took   time.Duration
+	err    error
+}
+
+var varRef = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// read a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scenario{}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("%s: no steps", path)
+	}
+	if s.Name == "" {
+		s.Name = path
+	}
+
+	return s, nil
+}
+
+// run a scenario file against a chain and print a pass/fail report:
+// sendtx run scenario.yaml -chain local
+func run(args []string) error {
+	fs := newFlagSet("run")
+	o := options{}
+	o.register(fs, false)
+	fs.BoolVar(&tx.Strict, "strict", false, "fail on validation warnings")
+	fs.BoolVar(&tx.Preflight, "preflight", true, "check chain state before signing and refuse txs bound to fail")
+	cont := fs.String("continue", "", "true or false, overrides the scenario's continueOnFailure")
+	vars := map[string]string{}
+	fs.Func("var", "set a scenario variable, name=value, repeatable", func(s string) error {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("want name=value, got %q", s)
+		}
+		vars[k] = v
+		return nil
+	})
+
+	pos, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	s, err := LoadScenario(pos[0])
+	if err != nil {
+		return usageError{err}
+	}
+	if s.Vars == nil {
+		s.Vars = map[string]string{}
+	}
+	for k, v := range vars {
+		s.Vars[k] = v
+	}
+	switch *cont {
+	case "":
+	case "true", "false":
+		s.ContinueOnFailure = *cont == "true"
+	default:
+		return usageError{fmt.Errorf("-continue wants true or false, got %q", *cont)}
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	// the steps send txs, review the whole run once
+	s.allow = o.allow
+	var review strings.Builder
+	fmt.Fprintf(&review, "  scenario %s, %d steps:", s.Name, len(s.Steps))
+	for i, step := range s.Steps {
+		fmt.Fprintf(&review, "\n    %d %s %s", i+1, step.Op, step.Name)
+	}
+	if err := o.gate(review.String()); err != nil {
+		return err
+	}
+
+	reports := s.Run(txObj)
+
+	steps := make([]map[string]interface{}, 0, len(reports))
+	failed := false
+	for _, r := range reports {
+		step := map[string]interface{}{"name": r.name, "status": r.status, "ms": r.took.Milliseconds()}
+		if r.err != nil {
+			step["error"] = r.err.Error()
+		}
+		steps = append(steps, step)
+		failed = failed || r.status == "FAIL"
+	}
+
+	res := &Result{
+		Op:    "run",
+		Chain: o.c.Name,
+		Data:  map[string]interface{}{"scenario": s.Name, "steps": steps, "vars": s.Vars},
+		text:  strings.TrimSuffix(Report(s.Name, reports), "\n"),
+	}
+	if failed {
+		err := fmt.Errorf("scenario %s failed", s.Name)
+		res.Error = errorInfo(err)
+		emit(res)
+		return err
+	}
+
+	return emit(res)
+}
+
+// run the steps in order, stopping at a failure unless configured to continue
+func (s *Scenario) Run(txObj *tx.Tx) []stepReport {
+	reports := make([]stepReport, 0, len(s.Steps))
+	stopped := false
+	for i, step := range s.Steps {
+		name := step.Name
+		if name == "" {
+			name = step.Op
+		}
+		name = fmt.Sprintf("%d %s", i+1, name)
+
+		if stopped {
+			reports = append(reports, stepReport{name: name, status: "SKIP"})
+			continue
+		}
+
+		log.Printf("step %s", name)
+		start := time.Now()
+		err := s.runStep(txObj, &step)
+		r := stepReport{name: name, status: "PASS", took: time.Since(start), err: err}
+		if err != nil {
+			r.status = "FAIL"
+			log.Printf("step %s failed: %v", name, err)
+
+			cont := s.ContinueOnFailure
+			if step.ContinueOnFailure != nil {
+				cont = *step.ContinueOnFailure
+			}
+			stopped = !cont
+		}
+		reports = append(reports, r)
+	}
+
+	return reports
+}
+
+// run one step, then its captures and assertions
+func (s *Scenario) runStep(txObj *tx.Tx, step *Step) error {
+	res, err := s.exec(txObj, step)
+	if err != nil {
+		return err
+	}
+
+	for name, src := range step.Capture {
+		v, err := res.lookup(src)
+		if err != nil {
+			return fmt.Errorf("capture %s: %w", name, err)
+		}
+		s.Vars[name] = tx.FormatValue(v)
+		log.Printf("captured %s = %s", name, s.Vars[name])
+	}
+
+	for i, a := range step.Assert {
+		if err := s.check(txObj, res, &a); err != nil {
+			return fmt.Errorf("assert %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// run the op of a step with its variables expanded
+func (s *Scenario) exec(txObj *tx.Tx, step *Step) (*stepResult, error) {
+	params := map[string]string{}
+	for k, v := range step.Params {
+		e, err := s.expand(v)
+		if err != nil {
+			return nil, err
+		}
+		params[k] = e
+	}
+	args, err := s.expandAll(step.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	txObj.SignedTx = nil
+
+	switch step.Op {
+	case "wait":
+		sec, err := tx.ParseDuration(params["duration"])
+		if err != nil {
+			return nil, fmt.Errorf("wait: %w", err)
+		}
+		time.Sleep(time.Duration(sec) * time.Second)
+		return &stepResult{}, nil
+
+	case "fund":
+		if s.allow != nil {
+			if err := s.allow("fund"); err != nil {
+				return nil, err
+			}
+		}
+		if step.As != "" {
+			restore, err := tx.UseAccount(step.As, "admin")
+			if err != nil {
+				return nil, err
+			}
+			defer restore()
+		}
+		return &stepResult{}, fundStep(txObj, params, args)
+
+	case "call":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("call wants args: contract, method and its args")
+		}
+		as := step.As
+		if as == "" {
+			as = "user"
+		}
+		out, err := txObj.Call(args[0], args[1], args[2:], as)
+		if err != nil {
+			return nil, err
+		}
+
+		// a view call, keep its outputs
+		if txObj.SignedTx == nil {
+			parsed, _, err := tx.ContractByName(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return &stepResult{out: tx.OutputFields(parsed.Methods[args[1]].Outputs, out)}, nil
+		}
+		return s.send(txObj)
+	}
+
+	op, ok := txOps[step.Op]
+	if !ok {
+		return nil, fmt.Errorf("unknown op %q", step.Op)
+	}
+	if step.As != "" {
+		restore, err := tx.UseAccount(step.As, op.role)
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+	}
+
+	fs := flag.NewFlagSet(op.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	build := op.setup(fs)
+	if err := fs.Parse(flagArgs(params)); err != nil {
+		return nil, fmt.Errorf("%s params: %w", op.name, err)
+	}
+
+	if err := build(txObj); err != nil {
+		return nil, err
+	}
+
+	return s.send(txObj)
+}
+
+// send the signed tx and decode the events of its receipt
+func (s *Scenario) send(txObj *tx.Tx) (*stepResult, error) {
+	// a call that cannot be decoded is named by its address and selector, which the allowlist refuses
+	// unless it allows them
+	if s.allow != nil {
+		if err := s.allow(tx.CallName(txObj.SignedTx.To(), txObj.SignedTx.Data())); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := txObj.Send(); err != nil {
+		return nil, err
+	}
+
+	receipt, err := txObj.Receipt()
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, &tx.RevertError{Hash: receipt.TxHash}
+	}
+
+	return &stepResult{receipt: receipt, events: tx.DecodeLogs(receipt.Logs)}, nil
+}
+
+// top up the accounts of a fund step, like the fund command
+func fundStep(txObj *tx.Tx, params map[string]string, args []string) error {
+	if len(args) == 0 {
+		args = []string{"user", "provider"}
+	}
+
+	var accounts []common.Address
+	for _, s := range args {
+		addr, err := tx.ResolveAddress(s)
+		if err != nil {
+			return err
+		}
+		accounts = append(accounts, addr)
+	}
+
+	var ethWei, creditWei *big.Int
+	if s := params["eth"]; s != "" {
+		v, err := tx.ParseCredit(s, 18)
+		if err != nil {
+			return err
+		}
+		ethWei = v
+	}
+	if s := params["credit"]; s != "" {
+		v, err := tx.Credit.Parse(s)
+		if err != nil {
+			return err
+		}
+		creditWei = v
+	}
+
+	_, _, err := txObj.Fund(tx.A_SK, accounts, ethWei, creditWei)
+
+	return err
+}
+
+// params as flags, sorted for a stable order
+func flagArgs(params map[string]string) []string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, "-"+k+"="+params[k])
+	}
+
+	return args
+}
+
+// replace ${name} with the scenario's variables
+func (s *Scenario) expand(v string) (string, error) {
+	var err error
+	out := varRef.ReplaceAllStringFunc(v, func(ref string) string {
+		name := varRef.FindStringSubmatch(ref)[1]
+		val, ok := s.Vars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %s", name)
+		}
+		return val
+	})
+
+	return out, err
+}
+
+func (s *Scenario) expandAll(vs []string) ([]string, error) {
+	out := make([]string, 0, len(vs))
+	for _, v := range vs {
+		e, err := s.expand(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+
+	return out, nil
+}
+
+// a value of the result: tx.hash, tx.block, tx.gasUsed,
+// event.<Name>.<field> of the first such event, or out.<field> of a view call
+func (res *stepResult) lookup(src string) (interface{}, error) {
+	kind, path, _ := strings.Cut(src, ".")
+	switch kind {
+	case "tx":
+		if res.receipt == nil {
+			return nil, fmt.Errorf("%s: step sent no tx", src)
+		}
+		switch path {
+		case "hash":
+			return res.receipt.TxHash, nil
+		case "block":
+			return res.receipt.BlockNumber, nil
+		case "gasUsed":
+			return res.receipt.GasUsed, nil
+		}
+	case "event":
+		name, field, _ := strings.Cut(path, ".")
+		for _, ev := range res.events {
+			if ev.Name == name {
+				return tx.FieldPath(ev.Fields, field)
+			}
+		}
+		return nil, fmt.Errorf("%s: no %s event", src, name)
+	case "out":
+		if res.out == nil {
+			return nil, fmt.Errorf("%s: step made no view call", src)
+		}
+		return tx.FieldPath(res.out, path)
+	}
+
+	return nil, fmt.Errorf("unknown source %q, want tx.hash, tx.block, tx.gasUsed, event.<Name>.<field> or out.<field>", src)
+}
+
+// check an assertion against the step's result or chain state
+func (s *Scenario) check(txObj *tx.Tx, res *stepResult, a *Assertion) error {
+	switch {
+	case a.Event != "":
+		want := map[string]string{}
+		for k, v := range a.Fields {
+			e, err := s.expand(v)
+			if err != nil {
+				return err
+			}
+			want[k] = e
+		}
+
+		for _, ev := range res.events {
+			if ev.Name == a.Event && fieldsMatch(ev, want) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no %s event with %v in the receipt", a.Event, want)
+
+	case len(a.Call) > 0:
+		args, err := s.expandAll(a.Call)
+		if err != nil {
+			return err
+		}
+		if len(args) < 2 {
+			return fmt.Errorf("call wants contract, method and its args")
+		}
+		want, err := s.expand(a.Equals)
+		if err != nil {
+			return err
+		}
+		as := a.As
+		if as == "" {
+			as = "user"
+		}
+
+		parsed, _, err := tx.ContractByName(args[0])
+		if err != nil {
+			return err
+		}
+		m, ok := parsed.Methods[args[1]]
+		if !ok || !m.IsConstant() {
+			return fmt.Errorf("%s.%s is not a view", args[0], args[1])
+		}
+		out, err := txObj.Call(args[0], args[1], args[2:], as)
+		if err != nil {
+			return err
+		}
+
+		v, err := tx.FieldPath(tx.OutputFields(m.Outputs, out), a.Field)
+		if err != nil {
+			return err
+		}
+		if got := tx.FormatValue(v); !sameValue(got, want) {
+			return fmt.Errorf("%s.%s %s is %s, want %s", args[0], args[1], a.Field, got, want)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("assertion needs an event or a call")
+}
+
+// do the event's fields have the wanted values
+func fieldsMatch(ev *tx.Event, want map[string]string) bool {
+	for k, w := range want {
+		v, ok := tx.LookupField(ev.Fields, k)
+		if !ok || !sameValue(tx.FormatValue(v), w) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compare formatted values, addresses and aliases by address
+func sameValue(got, want string) bool {
+	if strings.EqualFold(got, want) {
+		return true
+	}
+	if common.IsHexAddress(got) {
+		if addr, err := tx.ResolveAddress(want); err == nil {
+			return addr == common.HexToAddress(got)
+		}
+	}
+
+	return false
+}
+
+// the pass/fail report of a run
+func Report(name string, reports []stepReport) string {
+	var b strings.Builder
+	counts := map[string]int{}
+
+	fmt.Fprintf(&b, "\nscenario %s\n", name)
+	for _, r := range reports {
+		counts[r.status]++
+		fmt.Fprintf(&b, "  %-4s  %-32s %8s", r.status, r.name, r.took.Round(time.Millisecond))
+		if r.err != nil {
+			fmt.Fprintf(&b, "  %v", r.err)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed, %d skipped\n", counts["PASS"], counts["FAIL"], counts["SKIP"])
+
+	return b.String()
+}
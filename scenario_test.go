@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+func TestScenarioSendRefusesUndecodedCall(t *testing.T) {
+	to := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	txObj := &tx.Tx{SignedTx: types.NewTx(&types.LegacyTx{To: &to, Data: []byte{1, 2, 3, 4}})}
+
+	o := &options{c: &tx.Chain{Name: "prod", AllowedOps: []string{"market.*"}}}
+	var named string
+	s := &Scenario{allow: func(op string) error {
+		named = op
+		return o.allow(op)
+	}}
+
+	// refused before sending, the tx has no client to send with
+	_, err := s.send(txObj)
+	var refused refusedError
+	if !errors.As(err, &refused) {
+		t.Fatalf("send: %v, want a refusal", err)
+	}
+	if want := to.Hex() + ".0x01020304"; named != want {
+		t.Errorf("allow got %q, want %q", named, want)
+	}
+}
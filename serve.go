@@ -0,0 +1,400 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// default config file of the serve tokens, see serve.example.json
+const tokensPath = "serve.json"
+
+// largest request body accepted
+const maxBody = 1 << 20
+
+// a client of serve and the endpoints it may use
+type serveToken struct {
+	// the token, or env:VAR to read it from the environment
+	Token string `json:"token"`
+	// endpoints like create-order or call, matched like path.Match, * for all
+	Endpoints []string `json:"endpoints"`
+
+	value string
+}
+
+// a request to an endpoint, every field is optional
+type serveRequest struct {
+	// flags of the op without the dash, e.g. {"deposit": "40 CRD", "node-id": 1}
+	Params map[string]json.RawMessage `json:"params"`
+	// contract, method and args of call
+	Contract string            `json:"contract"`
+	Method   string            `json:"method"`
+	Args     []json.RawMessage `json:"args"`
+	// account profile signing the tx, the op's default role when empty
+	As string `json:"as"`
+	// send the tx and wait for its receipt, also ?send=true
+	Send bool `json:"send"`
+}
+
+// an endpoint, a nil result with no error is an empty 200
+type serveHandler func(req *serveRequest) (*Result, error)
+
+type server struct {
+	o      *options
+	base   *tx.Tx
+	tokens map[string]*serveToken
+
+	// signers, contracts and abis are package state of tx,
+	// so txs are built, signed and submitted one at a time
+	mu sync.Mutex
+}
+
+// serve the tx commands over http:
+// POST /<command> with {"params": {...}, "as": "...", "send": true}, POST /call, GET /health
+func serve(args []string) error {
+	fs := newFlagSet("serve")
+	o := options{}
+	o.register(fs, false)
+	listen := fs.String("listen", "127.0.0.1:8645", "address to listen on")
+	tokens := fs.String("tokens", tokensPath, "auth tokens of the clients and their endpoints")
+	fs.BoolVar(&tx.Strict, "strict", false, "fail on validation warnings, e.g. a payload for an unconfigured provider")
+	fs.BoolVar(&tx.Preflight, "preflight", true, "check chain state before signing and refuse txs bound to fail")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if structured() {
+		return usageError{fmt.Errorf("serve answers in json, -output is not for it")}
+	}
+
+	s := &server{o: &o}
+	var err error
+	if s.tokens, err = loadTokens(*tokens); err != nil {
+		return err
+	}
+	if s.base, err = o.connect(); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	for _, name := range sortedKeys(txOps) {
+		mux.Handle("/"+name, s.endpoint(name, s.op(txOps[name])))
+	}
+	mux.Handle("/call", s.endpoint("call", s.call))
+	mux.HandleFunc("/health", s.health)
+
+	log.Printf("serving chain %s on http://%s", o.c.Label(), *listen)
+
+	return http.ListenAndServe(*listen, mux)
+}
+
+// read the tokens file, serve and an authenticated proxy refuse to start without tokens
+func loadTokens(path string) (map[string]*serveToken, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("the clients need their tokens: %w", err)
+	}
+
+	var cfg struct {
+		Tokens map[string]*serveToken `json:"tokens"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+
+	for name, t := range cfg.Tokens {
+		t.value = t.Token
+		if env, ok := strings.CutPrefix(t.Token, "env:"); ok {
+			t.value = os.Getenv(env)
+		}
+		if t.value == "" {
+			return nil, fmt.Errorf("%s: token %s is empty", path, name)
+		}
+		for _, e := range t.Endpoints {
+			if _, err := pathpkg.Match(e, ""); err != nil {
+				return nil, fmt.Errorf("%s: token %s has a bad endpoint %q: %w", path, name, e, err)
+			}
+		}
+	}
+
+	return cfg.Tokens, nil
+}
+
+// the client of the bearer token, and may it use every endpoint
+func auth(tokens map[string]*serveToken, r *http.Request, endpoints ...string) (client string, status int) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", http.StatusUnauthorized
+	}
+
+	for name, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.value)) != 1 {
+			continue
+		}
+		for _, endpoint := range endpoints {
+			if !t.allows(endpoint) {
+				return name, http.StatusForbidden
+			}
+		}
+		return name, http.StatusOK
+	}
+
+	return "", http.StatusUnauthorized
+}
+
+func (t *serveToken) allows(endpoint string) bool {
+	for _, e := range t.Endpoints {
+		if match, _ := pathpkg.Match(e, endpoint); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check the method and token, decode the request and answer with the result
+func (s *server) endpoint(name string, h serveHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			reply(w, http.StatusMethodNotAllowed, &Result{Op: name, Error: &ErrorInfo{"usage", "use POST"}})
+			return
+		}
+
+		client, status := auth(s.tokens, r, name)
+		if status != http.StatusOK {
+			reply(w, status, &Result{Op: name, Error: &ErrorInfo{"auth", http.StatusText(status)}})
+			return
+		}
+
+		req := &serveRequest{}
+		d := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+		d.DisallowUnknownFields()
+		if err := d.Decode(req); err != nil && !errors.Is(err, io.EOF) {
+			reply(w, http.StatusBadRequest, &Result{Op: name, Error: &ErrorInfo{"usage", "bad request: " + err.Error()}})
+			return
+		}
+		if q := r.URL.Query().Get("send"); q != "" {
+			send, err := strconv.ParseBool(q)
+			if err != nil {
+				reply(w, http.StatusBadRequest, &Result{Op: name, Error: &ErrorInfo{"usage", "bad send: " + q}})
+				return
+			}
+			req.Send = req.Send || send
+		}
+
+		log.Printf("serve: %s from %s, send %v", name, client, req.Send)
+		res, err := s.handle(h, req)
+		if res == nil {
+			res = &Result{Op: name}
+		}
+		if res.Chain == "" {
+			res.Chain = s.o.c.Name
+		}
+		if err != nil {
+			log.Printf("serve: %s from %s: %v", name, client, err)
+			res.Error = errorInfo(err)
+			reply(w, httpStatus(err), res)
+			return
+		}
+		reply(w, http.StatusOK, res)
+	})
+}
+
+// run the handler, a panic of a builder fails only its request
+func (s *server) handle(h serveHandler, req *serveRequest) (res *Result, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			res, err = nil, fmt.Errorf("internal error: %v", p)
+		}
+	}()
+
+	return h(req)
+}
+
+// the http status of a failure by its kind
+func httpStatus(err error) int {
+	switch errorKind(err) {
+	case "usage":
+		return http.StatusBadRequest
+	case "validation", "revert":
+		return http.StatusUnprocessableEntity
+	case "rpc":
+		return http.StatusBadGateway
+	}
+
+	return http.StatusInternalServerError
+}
+
+func reply(w http.ResponseWriter, status int, res *Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
+
+// the chain and contracts served, without auth
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	reply(w, http.StatusOK, &Result{
+		Op:    "health",
+		Chain: s.o.c.Name,
+		Data: map[string]interface{}{
+			"deployment": s.o.c.Deployment,
+			"safety":     s.o.c.SafetyLevel(),
+			"contracts":  tx.Contracts,
+			"endpoints":  append(sortedKeys(txOps), "call"),
+		},
+	})
+}
+
+// a json value as a flag or arg string, strings unquoted and the rest as written
+func rawString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	return strings.TrimSpace(string(raw))
+}
+
+// the endpoint of a tx command, its params are the command's flags
+func (s *server) op(op txOp) serveHandler {
+	return func(req *serveRequest) (*Result, error) {
+		if req.Contract != "" || req.Method != "" || len(req.Args) > 0 {
+			return nil, usageError{fmt.Errorf("%s takes params, contract, method and args are for call", op.name)}
+		}
+
+		// a spec is a file of the server, clients give its fields as params
+		if _, ok := req.Params["spec"]; ok {
+			return nil, usageError{fmt.Errorf("%s takes the order fields as params, spec files are not read for clients", op.name)}
+		}
+		params := map[string]string{}
+		for k, v := range req.Params {
+			params[k] = rawString(v)
+		}
+		fs := flag.NewFlagSet(op.name, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		build := op.setup(fs)
+		if err := fs.Parse(flagArgs(params)); err != nil {
+			return nil, usageError{fmt.Errorf("params: %w", err)}
+		}
+
+		return s.sign(req, op.role, op.label, build)
+	}
+}
+
+// the endpoint of call, a view answers with its outputs
+func (s *server) call(req *serveRequest) (*Result, error) {
+	if req.Contract == "" || req.Method == "" {
+		return nil, usageError{fmt.Errorf("call wants contract and method")}
+	}
+	if len(req.Params) > 0 {
+		return nil, usageError{fmt.Errorf("call takes args, params are for the tx commands")}
+	}
+	args := make([]string, len(req.Args))
+	for i, a := range req.Args {
+		args[i] = rawString(a)
+	}
+	as := req.As
+	if as == "" {
+		as = "user"
+	}
+
+	var view *Result
+	r, err := s.sign(req, "", req.Contract+"."+req.Method, func(txObj *tx.Tx) error {
+		out, err := txObj.Call(req.Contract, req.Method, args, as)
+		if err != nil || txObj.SignedTx != nil {
+			return err
+		}
+
+		parsed, _, err := tx.ContractByName(req.Contract)
+		if err != nil {
+			return err
+		}
+		outputs := parsed.Methods[req.Method].Outputs
+		view = &Result{Op: req.Contract + "." + req.Method, Outputs: tx.JSONValues(tx.OutputFields(outputs, out))}
+		return nil
+	})
+	if view != nil {
+		return view, err
+	}
+
+	return r, err
+}
+
+// build and sign the tx, and send it when asked, waiting for it outside the lock
+func (s *server) sign(req *serveRequest, role, label string, build func(txObj *tx.Tx) error) (*Result, error) {
+	txObj := s.base.Fork()
+	r, err := s.build(txObj, req, role, label, build)
+	if err != nil || r == nil || !req.Send {
+		return r, err
+	}
+
+	if err := txObj.Wait(); err != nil {
+		return r, err
+	}
+	if err := s.o.addReceipt(r, txObj); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// the locked part of sign: signers, build, sign and submit, so nonces follow each other
+func (s *server) build(txObj *tx.Tx, req *serveRequest, role, label string, build func(txObj *tx.Tx) error) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.As != "" && role != "" {
+		restore, err := tx.UseAccount(req.As, role)
+		if err != nil {
+			return nil, usageError{err}
+		}
+		defer restore()
+	}
+
+	if err := build(txObj); err != nil {
+		return nil, err
+	}
+	// a view call, nothing signed
+	if txObj.SignedTx == nil {
+		return nil, nil
+	}
+
+	r := s.o.txResult(txObj, label)
+	unsigned, hash := tx.Unsigned(txObj.SignedTx)
+	if js, err := unsigned.MarshalJSON(); err == nil {
+		r.Unsigned = js
+	}
+	r.SigHash = hash.Hex()
+	if !req.Send {
+		return r, nil
+	}
+
+	// there is no one to confirm at a prompt, -yes at start allows sends on confirm and protected chains
+	if err := s.o.allow(opName(r)); err != nil {
+		return r, err
+	}
+	if level := s.o.c.SafetyLevel(); level != tx.SafetyOpen && !assumeYes {
+		return r, refusedError{fmt.Errorf("chain %s is %s, start serve with -yes to send", s.o.c.Name, level)}
+	}
+	if err := txObj.Submit(); err != nil {
+		return r, err
+	}
+	r.Sent = true
+
+	return r, nil
+}
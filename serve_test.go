@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rockiecn/sendtx/internal/rpctest"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+const approveABI = `[{"type":"function","name":"approve","stateMutability":"nonpayable",
+	"inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+// a chain using abis with only approve in credit
+func testChain(t *testing.T) *tx.Chain {
+	t.Helper()
+
+	dir := t.TempDir()
+	for path, abi := range map[string]string{
+		"registry/Registry.abi": "[]",
+		"market/Market.abi":     "[]",
+		"credit/Credit.abi":     approveABI,
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(abi), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &tx.Chain{Name: "local", ChainID: 1337, ABIDir: dir}
+	c.Contracts.Credit = "0x00000000000000000000000000000000000000c1"
+	c.Contracts.Registry = "0x00000000000000000000000000000000000000c2"
+	c.Contracts.Market = "0x00000000000000000000000000000000000000c3"
+
+	return c
+}
+
+// a signing failure is the error of its request, serve keeps running
+func TestServeSigningError(t *testing.T) {
+	saved := tx.Contracts
+	defer func() { tx.Contracts = saved }()
+
+	c := testChain(t)
+	if err := c.Use(); err != nil {
+		t.Fatal(err)
+	}
+
+	chainID := "0x539"
+	s := &server{
+		o: &options{c: c},
+		base: tx.NewTx(rpctest.Serve(t, rpctest.Handlers{
+			"eth_chainId":  func(json.RawMessage) (interface{}, error) { return chainID, nil },
+			"eth_gasPrice": func(json.RawMessage) (interface{}, error) { return "0x3b9aca00", nil },
+			"eth_getTransactionCount": func(json.RawMessage) (interface{}, error) {
+				return nil, errors.New("nonce unavailable")
+			},
+		})),
+		tokens: map[string]*serveToken{"ci": {Endpoints: []string{"*"}, value: "secret"}},
+	}
+	h := s.endpoint("approve", s.op(txOps["approve"]))
+
+	for _, tc := range []struct {
+		name, chainID string
+		status        int
+		kind, message string
+	}{
+		{"nonce rpc fails", "0x539", http.StatusBadGateway, "rpc", "nonce unavailable"},
+		{"rpc of another chain", "0x1", http.StatusUnprocessableEntity, "validation", "expects 1337"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chainID = tc.chainID
+			req := httptest.NewRequest(http.MethodPost, "/approve", strings.NewReader(`{"params": {"amount": "wei:5"}}`))
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tc.status, w.Body)
+			}
+			var res Result
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Error == nil || res.Error.Kind != tc.kind || !strings.Contains(res.Error.Message, tc.message) {
+				t.Errorf("error %+v, want %s containing %q", res.Error, tc.kind, tc.message)
+			}
+		})
+	}
+}
+
+// a tx built without sending reserves no nonce, the tx sent next has the pending one
+func TestServeBuildThenSend(t *testing.T) {
+	saved := tx.Contracts
+	defer func() { tx.Contracts = saved }()
+
+	c := testChain(t)
+	if err := c.Use(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := common.HexToAddress(tx.U_ADDR)
+	defer tx.Nonces.Forget(user)
+	var sent []*types.Transaction
+	s := &server{
+		o: &options{c: c},
+		base: tx.NewTx(rpctest.Serve(t, rpctest.Handlers{
+			"eth_chainId":             rpctest.Answer("0x539"),
+			"eth_gasPrice":            rpctest.Answer("0x3b9aca00"),
+			"eth_getTransactionCount": rpctest.Answer("0x7"),
+			"eth_sendRawTransaction": func(params json.RawMessage) (interface{}, error) {
+				var raw []hexutil.Bytes
+				if err := json.Unmarshal(params, &raw); err != nil || len(raw) != 1 {
+					return nil, fmt.Errorf("bad params %s", params)
+				}
+				signed := new(types.Transaction)
+				if err := signed.UnmarshalBinary(raw[0]); err != nil {
+					return nil, err
+				}
+				sent = append(sent, signed)
+				return signed.Hash(), nil
+			},
+		})),
+	}
+	approve := func(send bool) uint64 {
+		t.Helper()
+		fs := flag.NewFlagSet("approve", flag.ContinueOnError)
+		build := txOps["approve"].setup(fs)
+		if err := fs.Parse([]string{"-amount", "wei:5"}); err != nil {
+			t.Fatal(err)
+		}
+		txObj := s.base.Fork()
+		if _, err := s.build(txObj, &serveRequest{Send: send}, "user", "approve", build); err != nil {
+			t.Fatalf("approve, send %v: %v", send, err)
+		}
+		return txObj.SignedTx.Nonce()
+	}
+
+	if n := approve(false); n != 7 {
+		t.Errorf("built nonce %d, want the pending 7", n)
+	}
+	approve(true)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d txs, want 1", len(sent))
+	}
+	if sent[0].Nonce() != 7 {
+		t.Errorf("sent nonce %d, want the pending 7", sent[0].Nonce())
+	}
+	if n := approve(false); n != 8 {
+		t.Errorf("built nonce %d after sending 7, want 8", n)
+	}
+}
+
+// clients cannot make serve read a file
+func TestServeRejectsSpec(t *testing.T) {
+	s := &server{o: &options{c: &tx.Chain{Name: "local"}}}
+	for _, name := range []string{"approve", "create-order"} {
+		_, err := s.op(txOps[name])(&serveRequest{Params: map[string]json.RawMessage{"spec": json.RawMessage(`"/etc/passwd"`)}})
+		if errorKind(err) != "usage" {
+			t.Errorf("%s with a spec: %v, want a usage error", name, err)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// print the cost of ordering a node
+func quote(args []string) error {
+	fs := newFlagSet("quote")
+	o := options{}
+	o.register(fs, false)
+	spec := tx.OrderSpec{}
+	loadSpec := orderFlags(fs, &spec, false)
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := loadSpec(); err != nil {
+		return err
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	q, err := txObj.QuoteOrder(&spec)
+	if err != nil {
+		return err
+	}
+
+	return emit(&Result{Op: "quote", Chain: o.c.Name, Data: q, text: q.String()})
+}
+
+// call any contract method by its abi:
+// sendtx call <registry|market|credit> <method> [args...] --as <role or account>
+func call(args []string) error {
+	fs := newFlagSet("call")
+	o := options{}
+	o.register(fs, true)
+	fs.StringVar(&o.as, "as", "user", "role or account profile signing the tx: user, provider, admin, alice")
+	usage := fs.Usage
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintln(fs.Output(), "\nargs are parsed by the abi type: addresses or aliases, ints like 42, 0x2a, 30d, 1.5 ether, 40 CRD, wei:40000000, hex bytes, json arrays and tuples")
+	}
+
+	pos, err := parseFlags(fs, args, 2, -1)
+	if err != nil {
+		return err
+	}
+	contract, method := pos[0], pos[1]
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	out, err := txObj.Call(contract, method, pos[2:], o.as)
+	if err != nil {
+		return err
+	}
+
+	// a view call, print its outputs
+	if txObj.SignedTx == nil {
+		parsed, _, err := tx.ContractByName(contract)
+		if err != nil {
+			return err
+		}
+		outputs := parsed.Methods[method].Outputs
+		return emit(&Result{
+			Op:      contract + "." + method,
+			Chain:   o.c.Name,
+			Outputs: tx.JSONValues(tx.OutputFields(outputs, out)),
+			text:    tx.FormatOutputs(outputs, out),
+		})
+	}
+
+	return o.finish(txObj, contract+"."+method)
+}
+
+// top up accounts with eth and credit from admin:
+// sendtx fund [accounts...] -eth 1 -credit 100
+func fund(args []string) error {
+	fs := newFlagSet("fund")
+	o := options{}
+	o.register(fs, false)
+	o.asFlag(fs, "admin")
+	ethTarget := fs.String("eth", "", "target ETH balance of each account, e.g. 0.5, empty to skip")
+	creditTarget := fs.String("credit", "", "target credit balance of each account, e.g. 100 or wei:40000000, empty to skip")
+	usage := fs.Usage
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintln(fs.Output(), "\naccounts are addresses, aliases or account profiles, user and provider by default")
+	}
+
+	pos, err := parseFlags(fs, args, 0, -1)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 {
+		pos = []string{"user", "provider"}
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	// account profiles are loaded with the chain
+	var accounts []common.Address
+	for _, s := range pos {
+		addr, err := tx.ResolveAddress(s)
+		if err != nil {
+			return usageError{err}
+		}
+		accounts = append(accounts, addr)
+	}
+
+	var ethWei, creditWei *big.Int
+	if *ethTarget != "" {
+		v, err := tx.ParseCredit(*ethTarget, 18)
+		if err != nil {
+			return usageError{err}
+		}
+		ethWei = v
+	}
+	if *creditTarget != "" {
+		v, err := tx.Credit.Parse(*creditTarget)
+		if err != nil {
+			return usageError{err}
+		}
+		creditWei = v
+	}
+
+	if err := o.allow("fund"); err != nil {
+		return err
+	}
+	restore, err := o.act("admin")
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	var review strings.Builder
+	var targets []string
+	if ethWei != nil {
+		targets = append(targets, tx.FormatCredit(ethWei, 18)+" ETH")
+	}
+	if creditWei != nil {
+		targets = append(targets, tx.Credit.Format(creditWei))
+	}
+	fmt.Fprintf(&review, "  admin %s tops up %d accounts to %s", tx.ShortAddr(common.HexToAddress(tx.A_ADDR)), len(accounts), strings.Join(targets, " and "))
+	for _, a := range accounts {
+		fmt.Fprintf(&review, "\n    %s", a.Hex())
+	}
+	if err := o.gate(review.String()); err != nil {
+		return err
+	}
+
+	before, after, err := txObj.Fund(tx.A_SK, accounts, ethWei, creditWei)
+	r := &Result{
+		Op:    "fund",
+		Chain: o.c.Name,
+		Data:  map[string][]*tx.Balance{"before": before, "after": after},
+		text:  strings.TrimSuffix(tx.BalanceTable(before, after), "\n"),
+	}
+	if err != nil {
+		r.Error = errorInfo(err)
+	}
+	emit(r)
+
+	return err
+}
+
+// deploy the contracts to a fresh chain and write its contracts file
+func deploy(args []string) error {
+	fs := newFlagSet("deploy")
+	o := options{}
+	o.register(fs, false)
+	o.asFlag(fs, "admin")
+	artifacts := fs.String("artifacts", "../grid-contracts/out", "dir of the compiled contract artifacts")
+	out := fs.String("out", "", "contracts file to write, default the chain's contracts file")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	// a fresh chain has no contracts yet, only its rpc is used
+	c, err := o.resolve()
+	if err != nil {
+		return err
+	}
+	txObj := tx.NewTx(c.RPC)
+	if err := txObj.CheckChainID(c); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = c.ContractsFile
+	}
+	if *out == "" {
+		return usageError{fmt.Errorf("chain %s has no contracts file, give -out", c.Name)}
+	}
+
+	if err := o.allow("deploy"); err != nil {
+		return err
+	}
+	restore, err := o.act("admin")
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	review := fmt.Sprintf("  admin %s deploys %s from %s", tx.ShortAddr(common.HexToAddress(tx.A_ADDR)), strings.Join(tx.DeployOrder, ", "), *artifacts)
+	if err := o.gate(review); err != nil {
+		return err
+	}
+
+	cs, err := txObj.Deploy(tx.A_SK, *artifacts)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.WriteContracts(*out, cs); err != nil {
+		return err
+	}
+
+	return emit(&Result{
+		Op:    "deploy",
+		Chain: c.Name,
+		Data:  map[string]interface{}{"file": *out, "contracts": cs},
+		text:  fmt.Sprintf("contract addresses written to %s: %v", *out, cs),
+	})
+}
+
+// import the contract addresses a foundry script deployed into the chain's contracts file:
+// sendtx import broadcast/Deploy.s.sol or sendtx import broadcast/Deploy.s.sol/1337/run-latest.json
+func importBroadcast(args []string) error {
+	fs := newFlagSet("import")
+	o := options{}
+	o.register(fs, false)
+	out := fs.String("out", "", "contracts file to write or update, default the chain's contracts file")
+	pos, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	c, err := o.resolve()
+	if err != nil {
+		return err
+	}
+	txObj := tx.NewTx(c.RPC)
+	if err := txObj.CheckChainID(c); err != nil {
+		return err
+	}
+	id, err := txObj.ChainID()
+	if err != nil {
+		return err
+	}
+
+	path, err := tx.BroadcastPath(pos[0], id.Uint64())
+	if err != nil {
+		return usageError{err}
+	}
+	bc, err := tx.ReadBroadcast(path)
+	if err != nil {
+		return err
+	}
+	if id.Cmp(new(big.Int).SetUint64(bc.Chain)) != 0 {
+		return fmt.Errorf("%s was broadcast to chain %d, but rpc %s is chain %s", path, bc.Chain, c.RPC, id)
+	}
+
+	if *out == "" {
+		*out = c.ContractsFile
+	}
+	if *out == "" {
+		return usageError{fmt.Errorf("chain %s has no contracts file, give -out", c.Label())}
+	}
+
+	before, after, err := bc.Merge(*out)
+	if err != nil {
+		return err
+	}
+
+	// code must be at every address before it is written
+	tx.Contracts = after
+	if err := txObj.CheckChain(c); err != nil {
+		return err
+	}
+
+	if err := tx.WriteContracts(*out, after); err != nil {
+		return err
+	}
+
+	return emit(&Result{
+		Op:    "import",
+		Chain: c.Name,
+		Data:  map[string]interface{}{"file": *out, "broadcast": path, "before": before, "contracts": after},
+		text:  fmt.Sprintf("contract addresses from %s written to %s: %v", path, *out, after),
+	})
+}
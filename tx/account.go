@@ -0,0 +1,227 @@
+package tx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// default config file of the account profiles, see accounts.example.json
+var AccountsPath = "accounts.json"
+
+// the loaded account profiles by name, see LoadAccounts
+var Accounts = map[string]*Account{}
+
+// a named account acting as a user, provider or admin
+type Account struct {
+	Name string `json:"-"`
+
+	// where the key comes from: a hex key, env:VAR, file:path with a hex key,
+	// or keystore:path with its password in PasswordEnv
+	Key         string `json:"key,omitempty"`
+	PasswordEnv string `json:"passwordEnv,omitempty"`
+
+	// address of an account without a key, usable as an alias only
+	Address string `json:"address,omitempty"`
+
+	// the role the account is meant for: user, provider or admin, any when empty
+	Role string `json:"role,omitempty"`
+
+	// loaded key and address
+	sk   string
+	addr common.Address
+}
+
+// the accounts known without a config file, the default signers of each role
+func builtinAccounts() map[string]*Account {
+	return map[string]*Account{
+		"admin":    {Key: A_SK, Role: "admin"},
+		"user":     {Key: U_SK, Role: "user"},
+		"provider": {Key: P_SK, Role: "provider"},
+	}
+}
+
+// read the account profiles at path on top of the builtin ones into Accounts,
+// a missing file at the default path only gives the builtins
+func LoadAccounts(path string) error {
+	accounts := builtinAccounts()
+
+	b, err := os.ReadFile(path)
+	if err != nil && !(os.IsNotExist(err) && path == AccountsPath) {
+		return err
+	}
+	if err == nil {
+		var cfg struct {
+			Accounts map[string]*Account `json:"accounts"`
+		}
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		// key files are relative to the config file
+		dir := filepath.Dir(path)
+		for name, a := range cfg.Accounts {
+			for _, prefix := range []string{"file:", "keystore:"} {
+				if p, ok := strings.CutPrefix(a.Key, prefix); ok && !filepath.IsAbs(p) {
+					a.Key = prefix + filepath.Join(dir, p)
+				}
+			}
+			switch a.Role {
+			case "", "user", "provider", "admin":
+			default:
+				return fmt.Errorf("%s: account %s has role %q, want user, provider or admin", path, name, a.Role)
+			}
+			if a.Key == "" && !common.IsHexAddress(a.Address) {
+				return fmt.Errorf("%s: account %s needs a key or an address", path, name)
+			}
+			accounts[name] = a
+		}
+	}
+
+	for name, a := range accounts {
+		a.Name = name
+		if a.Key == "" {
+			a.addr = common.HexToAddress(a.Address)
+		}
+	}
+	Accounts = accounts
+
+	return nil
+}
+
+// names of the loaded accounts, sorted
+func AccountNames() []string {
+	names := make([]string, 0, len(Accounts))
+	for name := range Accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// find an account by its name
+func GetAccount(name string) (*Account, error) {
+	a, ok := Accounts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %q, want one of %v", name, AccountNames())
+	}
+
+	return a, nil
+}
+
+// the hex key of the account, read from its source on first use
+func (a *Account) SK() (string, error) {
+	if a.sk != "" {
+		return a.sk, nil
+	}
+	if a.Key == "" {
+		return "", fmt.Errorf("account %s has only an address, it cannot sign", a.Name)
+	}
+
+	var sk string
+	switch {
+	case strings.HasPrefix(a.Key, "env:"):
+		name := strings.TrimPrefix(a.Key, "env:")
+		sk = os.Getenv(name)
+		if sk == "" {
+			return "", fmt.Errorf("account %s: env %s is empty", a.Name, name)
+		}
+	case strings.HasPrefix(a.Key, "file:"):
+		b, err := os.ReadFile(strings.TrimPrefix(a.Key, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("account %s: %w", a.Name, err)
+		}
+		sk = string(b)
+	case strings.HasPrefix(a.Key, "keystore:"):
+		b, err := os.ReadFile(strings.TrimPrefix(a.Key, "keystore:"))
+		if err != nil {
+			return "", fmt.Errorf("account %s: %w", a.Name, err)
+		}
+		password := ""
+		if a.PasswordEnv != "" {
+			password = os.Getenv(a.PasswordEnv)
+		}
+		key, err := keystore.DecryptKey(b, password)
+		if err != nil {
+			return "", fmt.Errorf("account %s: %w", a.Name, err)
+		}
+		sk = hex.EncodeToString(crypto.FromECDSA(key.PrivateKey))
+	default:
+		sk = a.Key
+	}
+
+	sk = strings.TrimPrefix(strings.TrimSpace(sk), "0x")
+	addr, err := KeyAddress(sk)
+	if err != nil {
+		return "", fmt.Errorf("account %s: bad key: %w", a.Name, err)
+	}
+	a.sk, a.addr = sk, addr
+
+	return sk, nil
+}
+
+// the address of the account, its key is read for it when needed
+func (a *Account) Addr() (common.Address, error) {
+	if a.addr == (common.Address{}) {
+		if _, err := a.SK(); err != nil {
+			return common.Address{}, err
+		}
+	}
+
+	return a.addr, nil
+}
+
+// is addr a known profile meant for role, profiles whose key was not read yet are not known
+func hasRole(addr common.Address, role string) bool {
+	for _, a := range Accounts {
+		if a.Role == role && a.addr == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sign the ops of role with the account, until restore is called.
+// the account should be meant for the role, like the default signers
+func UseAccount(name, role string) (restore func(), err error) {
+	a, err := GetAccount(name)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := a.SK()
+	if err != nil {
+		return nil, err
+	}
+	if a.Role != "" && a.Role != role {
+		if err := warn("account %s is a %s, acting as the %s", name, a.Role, role); err != nil {
+			return nil, err
+		}
+	}
+
+	var skVar, addrVar *string
+	switch role {
+	case "provider":
+		skVar, addrVar = &P_SK, &P_ADDR
+	case "user":
+		skVar, addrVar = &U_SK, &U_ADDR
+	case "admin":
+		skVar, addrVar = &A_SK, &A_ADDR
+	default:
+		return nil, fmt.Errorf("unknown role %q, want user, provider or admin", role)
+	}
+
+	oldSK, oldAddr := *skVar, *addrVar
+	*skVar, *addrVar = sk, a.addr.Hex()
+
+	return func() { *skVar, *addrVar = oldSK, oldAddr }, nil
+}
@@ -0,0 +1,319 @@
+package tx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// units accepted after an integer argument, as powers of ten
+var intUnits = map[string]int{
+	"wei":   0,
+	"gwei":  9,
+	"ether": 18,
+	"eth":   18,
+}
+
+// parse the string args of a method by its abi inputs
+func ParseArgs(args abi.Arguments, ss []string) ([]interface{}, error) {
+	if len(ss) != len(args) {
+		return nil, fmt.Errorf("want %d args, got %d", len(args), len(ss))
+	}
+
+	vals := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := ParseArg(arg.Type, ss[i])
+		if err != nil {
+			return nil, fmt.Errorf("arg %d (%s %s): %w", i, arg.Type, arg.Name, err)
+		}
+		vals[i] = v
+	}
+
+	return vals, nil
+}
+
+// parse a string into the go value abi packs for t, arrays and tuples are given as json
+func ParseArg(t abi.Type, s string) (interface{}, error) {
+	var v interface{} = s
+
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		d := json.NewDecoder(strings.NewReader(s))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+	}
+
+	rv, err := argValue(t, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return rv.Interface(), nil
+}
+
+// convert a decoded json value into a value of the go type of t
+func argValue(t abi.Type, v interface{}) (reflect.Value, error) {
+	typ := t.GetType()
+
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy:
+		list, ok := v.([]interface{})
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("want a json array for %s", t)
+		}
+		if t.T == abi.ArrayTy && len(list) != t.Size {
+			return reflect.Value{}, fmt.Errorf("want %d elements for %s, got %d", t.Size, t, len(list))
+		}
+
+		rv := reflect.New(typ).Elem()
+		if t.T == abi.SliceTy {
+			rv = reflect.MakeSlice(typ, len(list), len(list))
+		}
+		for i, e := range list {
+			ev, err := argValue(*t.Elem, e)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("[%d]: %w", i, err)
+			}
+			rv.Index(i).Set(ev)
+		}
+		return rv, nil
+
+	case abi.TupleTy:
+		rv := reflect.New(typ).Elem()
+		switch fields := v.(type) {
+		case []interface{}:
+			// positional fields
+			if len(fields) != len(t.TupleElems) {
+				return reflect.Value{}, fmt.Errorf("want %d fields for tuple, got %d", len(t.TupleElems), len(fields))
+			}
+			for i, e := range fields {
+				ev, err := argValue(*t.TupleElems[i], e)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("%s: %w", t.TupleRawNames[i], err)
+				}
+				rv.Field(i).Set(ev)
+			}
+		case map[string]interface{}:
+			// named fields, matched by raw or go name
+			for i, name := range t.TupleRawNames {
+				e, ok := LookupField(fields, name)
+				if !ok {
+					return reflect.Value{}, fmt.Errorf("missing tuple field %s", name)
+				}
+				ev, err := argValue(*t.TupleElems[i], e)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("%s: %w", name, err)
+				}
+				rv.Field(i).Set(ev)
+			}
+			if len(fields) > len(t.TupleRawNames) {
+				return reflect.Value{}, fmt.Errorf("unknown fields in tuple, want %v", t.TupleRawNames)
+			}
+		default:
+			return reflect.Value{}, fmt.Errorf("want a json object or array for tuple")
+		}
+		return rv, nil
+	}
+
+	// scalars come as strings, or as json numbers and bools inside arrays and tuples
+	var s string
+	switch e := v.(type) {
+	case string:
+		s = e
+	case json.Number:
+		s = e.String()
+	case bool:
+		s = strconv.FormatBool(e)
+	default:
+		return reflect.Value{}, fmt.Errorf("unexpected value %v for %s", v, t)
+	}
+
+	return scalarValue(t, typ, s)
+}
+
+// find a field of json, outputs or an event by its raw abi name or the go field name
+func LookupField(fields map[string]interface{}, name string) (interface{}, bool) {
+	for k, v := range fields {
+		if strings.EqualFold(k, name) || strings.EqualFold(k, abi.ToCamelCase(name)) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// parse a scalar abi value
+func scalarValue(t abi.Type, typ reflect.Type, s string) (reflect.Value, error) {
+	s = strings.TrimSpace(s)
+
+	switch t.T {
+	case abi.AddressTy:
+		a, err := ResolveAddress(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(a), nil
+
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b), nil
+
+	case abi.StringTy:
+		return reflect.ValueOf(s), nil
+
+	case abi.BytesTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b), nil
+
+	case abi.FixedBytesTy, abi.HashTy:
+		b, err := hexutil.Decode(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if len(b) != typ.Len() {
+			return reflect.Value{}, fmt.Errorf("want %d bytes, got %d", typ.Len(), len(b))
+		}
+		rv := reflect.New(typ).Elem()
+		reflect.Copy(rv, reflect.ValueOf(b))
+		return rv, nil
+
+	case abi.IntTy, abi.UintTy:
+		n, err := ParseInt(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return intValue(t, typ, n)
+	}
+
+	return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+}
+
+// parse an integer given as decimal, hex, a duration, or a number with a unit:
+// 42, 0x2a, 30d, 1.5 ether, 40 CRD, wei:40000000
+func ParseInt(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+
+	// credit amounts
+	if strings.HasPrefix(s, WeiPrefix) || strings.HasSuffix(strings.ToUpper(s), " "+strings.ToUpper(Credit.Symbol)) {
+		return Credit.Parse(s)
+	}
+
+	// eth units
+	if num, unit, ok := strings.Cut(s, " "); ok {
+		exp, ok := intUnits[strings.ToLower(strings.TrimSpace(unit))]
+		if !ok {
+			return nil, fmt.Errorf("unknown unit in %q", s)
+		}
+		return ParseCredit(num, uint8(exp))
+	}
+
+	// hex
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, ok := new(big.Int).SetString(s[2:], 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid hex integer %q", s)
+		}
+		return n, nil
+	}
+
+	if n, ok := new(big.Int).SetString(s, 10); ok {
+		return n, nil
+	}
+
+	// durations in seconds
+	if d, err := ParseDuration(s); err == nil {
+		return new(big.Int).SetUint64(d), nil
+	}
+
+	return nil, fmt.Errorf("invalid integer %q", s)
+}
+
+// convert n into the go int type of t with a range check
+func intValue(t abi.Type, typ reflect.Type, n *big.Int) (reflect.Value, error) {
+	// the range of an int or uint of t.Size bits
+	min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(t.Size))
+	if t.T == abi.IntTy {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	max.Sub(max, big.NewInt(1))
+	if n.Cmp(min) < 0 || n.Cmp(max) > 0 {
+		return reflect.Value{}, fmt.Errorf("%s out of range for %s", n, t)
+	}
+
+	if typ == reflect.TypeOf(&big.Int{}) {
+		return reflect.ValueOf(n), nil
+	}
+
+	rv := reflect.New(typ).Elem()
+	if t.T == abi.IntTy {
+		rv.SetInt(n.Int64())
+	} else {
+		rv.SetUint(n.Uint64())
+	}
+
+	return rv, nil
+}
+
+// bytes and fixed bytes as hex instead of base64 and number arrays
+func hexBytes(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		return hexutil.Bytes(rv.Bytes())
+	case rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8:
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return hexutil.Bytes(b)
+	}
+	return v
+}
+
+// format method outputs as indented json
+func FormatOutputs(args abi.Arguments, out []interface{}) string {
+	vals := JSONValues(OutputFields(args, out))
+
+	b, err := json.MarshalIndent(vals, "", "  ")
+	if err != nil {
+		return fmt.Sprint(out)
+	}
+
+	return string(b)
+}
+
+// outputs of a call by their names, unnamed ones by their index
+func OutputFields(args abi.Arguments, out []interface{}) map[string]interface{} {
+	vals := make(map[string]interface{}, len(out))
+	for i, v := range out {
+		name := args[i].Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		vals[name] = v
+	}
+
+	return vals
+}
+
+// values ready for json, with bytes in hex instead of base64
+func JSONValues(fields map[string]interface{}) map[string]interface{} {
+	vals := make(map[string]interface{}, len(fields))
+	for name, v := range fields {
+		vals[name] = hexBytes(v)
+	}
+
+	return vals
+}
@@ -0,0 +1,140 @@
+package tx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/eth/contracts"
+)
+
+// a foundry broadcast file, broadcast/<script>/<chainid>/run-latest.json
+type Broadcast struct {
+	Transactions []struct {
+		Hash            string `json:"hash"`
+		TransactionType string `json:"transactionType"`
+		ContractName    string `json:"contractName"`
+		ContractAddress string `json:"contractAddress"`
+	} `json:"transactions"`
+	Receipts []struct {
+		TransactionHash string `json:"transactionHash"`
+		Status          string `json:"status"`
+	} `json:"receipts"`
+	Chain uint64 `json:"chain"`
+
+	// the file it was read from
+	Path string `json:"-"`
+}
+
+// the broadcast file at path, a broadcast/<script> dir is read at <chainID>/run-latest.json
+func BroadcastPath(path string, chainID uint64) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return path, nil
+	}
+
+	return filepath.Join(path, strconv.FormatUint(chainID, 10), "run-latest.json"), nil
+}
+
+// read a foundry broadcast file
+func ReadBroadcast(path string) (*Broadcast, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bc := &Broadcast{Path: path}
+	if err := json.Unmarshal(b, bc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if bc.Chain == 0 {
+		return nil, fmt.Errorf("%s: no chain id, is it a foundry broadcast file?", path)
+	}
+
+	return bc, nil
+}
+
+// the addresses of the contracts in DeployOrder created by the broadcast, by name.
+// a contract created twice takes the later address, failed creates are skipped
+func (bc *Broadcast) Created() (map[string]common.Address, error) {
+	failed := map[string]bool{}
+	for _, r := range bc.Receipts {
+		if r.Status == "0x0" {
+			failed[r.TransactionHash] = true
+		}
+	}
+
+	want := map[string]bool{}
+	for _, name := range DeployOrder {
+		want[name] = true
+	}
+
+	created := map[string]common.Address{}
+	for _, t := range bc.Transactions {
+		if t.TransactionType != "CREATE" && t.TransactionType != "CREATE2" {
+			continue
+		}
+		if !want[t.ContractName] || failed[t.Hash] {
+			continue
+		}
+		if !common.IsHexAddress(t.ContractAddress) {
+			return nil, fmt.Errorf("%s: %s has a bad address %q", bc.Path, t.ContractName, t.ContractAddress)
+		}
+		created[t.ContractName] = common.HexToAddress(t.ContractAddress)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("%s: no CREATE of %v", bc.Path, DeployOrder)
+	}
+
+	return created, nil
+}
+
+// set the created addresses on cs, the others are kept
+func updateContracts(cs *contracts.Contracts, created map[string]common.Address) {
+	if a, ok := created["Credit"]; ok {
+		cs.Credit = a.Hex()
+	}
+	if a, ok := created["Registry"]; ok {
+		cs.Registry = a.Hex()
+	}
+	if a, ok := created["Market"]; ok {
+		cs.Market = a.Hex()
+	}
+}
+
+// the addresses of the contracts file at path updated with the created ones,
+// a missing file starts empty
+func (bc *Broadcast) Merge(path string) (before, after contracts.Contracts, err error) {
+	if _, err := os.Stat(path); err == nil {
+		local := contracts.Local{}
+		if err := local.LoadPath(path); err != nil {
+			return before, after, fmt.Errorf("load %s: %w", path, err)
+		}
+		before = local.Contracts
+	}
+
+	created, err := bc.Created()
+	if err != nil {
+		return before, after, err
+	}
+	after = before
+	updateContracts(&after, created)
+
+	for _, cc := range []struct{ name, addr string }{
+		{"Credit", after.Credit},
+		{"Registry", after.Registry},
+		{"Market", after.Market},
+	} {
+		if cc.addr == "" {
+			return before, after, fmt.Errorf("%s is not created in %s and not in %s", cc.name, bc.Path, path)
+		}
+	}
+
+	return before, after, nil
+}
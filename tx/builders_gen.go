@@ -0,0 +1,161 @@
+// Code generated by go run ./gen; DO NOT EDIT.
+
+package tx
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/go/market"
+	"github.com/grid/contracts/go/registry"
+)
+
+// RegistryAddNodeData packs the data for calling registry.add_node
+func RegistryAddNodeData(node registry.IRegistryNode) ([]byte, error) {
+	return PackData(RegABI, "add_node", node)
+}
+
+// MakeRegistryAddNodeTx makes a signed tx calling registry.add_node, sent from the owner of sk
+func (tx *Tx) MakeRegistryAddNodeTx(sk string, node registry.IRegistryNode) error {
+	data, err := RegistryAddNodeData(node)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Registry), data)
+}
+
+// RegistryRegisterData packs the data for calling registry.register
+func RegistryRegisterData(cp registry.IRegistryCP) ([]byte, error) {
+	return PackData(RegABI, "register", cp)
+}
+
+// MakeRegistryRegisterTx makes a signed tx calling registry.register, sent from the owner of sk
+func (tx *Tx) MakeRegistryRegisterTx(sk string, cp registry.IRegistryCP) error {
+	data, err := RegistryRegisterData(cp)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Registry), data)
+}
+
+// RegistryReviseData packs the data for calling registry.revise
+func RegistryReviseData(cp registry.IRegistryCP) ([]byte, error) {
+	return PackData(RegABI, "revise", cp)
+}
+
+// MakeRegistryReviseTx makes a signed tx calling registry.revise, sent from the owner of sk
+func (tx *Tx) MakeRegistryReviseTx(sk string, cp registry.IRegistryCP) error {
+	data, err := RegistryReviseData(cp)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Registry), data)
+}
+
+// RegistryUpdatecpData packs the data for calling registry.updatecp
+func RegistryUpdatecpData(cp registry.IRegistryCP) ([]byte, error) {
+	return PackData(RegABI, "updatecp", cp)
+}
+
+// MakeRegistryUpdatecpTx makes a signed tx calling registry.updatecp, sent from the owner of sk
+func (tx *Tx) MakeRegistryUpdatecpTx(sk string, cp registry.IRegistryCP) error {
+	data, err := RegistryUpdatecpData(cp)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Registry), data)
+}
+
+// MarketCreateOrderData packs the data for calling market.createOrder
+func MarketCreateOrderData(order market.IMarketOrder) ([]byte, error) {
+	return PackData(MarketABI, "createOrder", order)
+}
+
+// MakeMarketCreateOrderTx makes a signed tx calling market.createOrder, sent from the owner of sk
+func (tx *Tx) MakeMarketCreateOrderTx(sk string, order market.IMarketOrder) error {
+	data, err := MarketCreateOrderData(order)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Market), data)
+}
+
+// MarketUserCancelData packs the data for calling market.userCancel
+func MarketUserCancelData(provider common.Address) ([]byte, error) {
+	return PackData(MarketABI, "userCancel", provider)
+}
+
+// MakeMarketUserCancelTx makes a signed tx calling market.userCancel, sent from the owner of sk
+func (tx *Tx) MakeMarketUserCancelTx(sk string, provider common.Address) error {
+	data, err := MarketUserCancelData(provider)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Market), data)
+}
+
+// MarketUserConfirmData packs the data for calling market.userConfirm
+func MarketUserConfirmData(provider common.Address) ([]byte, error) {
+	return PackData(MarketABI, "userConfirm", provider)
+}
+
+// MakeMarketUserConfirmTx makes a signed tx calling market.userConfirm, sent from the owner of sk
+func (tx *Tx) MakeMarketUserConfirmTx(sk string, provider common.Address) error {
+	data, err := MarketUserConfirmData(provider)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Market), data)
+}
+
+// CreditApproveData packs the data for calling credit.approve
+func CreditApproveData(spender common.Address, amount *big.Int) ([]byte, error) {
+	return PackData(CreditABI, "approve", spender, amount)
+}
+
+// MakeCreditApproveTx makes a signed tx calling credit.approve, sent from the owner of sk
+func (tx *Tx) MakeCreditApproveTx(sk string, spender common.Address, amount *big.Int) error {
+	data, err := CreditApproveData(spender, amount)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Credit), data)
+}
+
+// CreditTransferData packs the data for calling credit.transfer
+func CreditTransferData(to common.Address, amount *big.Int) ([]byte, error) {
+	return PackData(CreditABI, "transfer", to, amount)
+}
+
+// MakeCreditTransferTx makes a signed tx calling credit.transfer, sent from the owner of sk
+func (tx *Tx) MakeCreditTransferTx(sk string, to common.Address, amount *big.Int) error {
+	data, err := CreditTransferData(to, amount)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Credit), data)
+}
+
+// CreditTransferFromData packs the data for calling credit.transferFrom
+func CreditTransferFromData(from common.Address, to common.Address, amount *big.Int) ([]byte, error) {
+	return PackData(CreditABI, "transferFrom", from, to, amount)
+}
+
+// MakeCreditTransferFromTx makes a signed tx calling credit.transferFrom, sent from the owner of sk
+func (tx *Tx) MakeCreditTransferFromTx(sk string, from common.Address, to common.Address, amount *big.Int) error {
+	data, err := CreditTransferFromData(from, to, amount)
+	if err != nil {
+		return err
+	}
+
+	return tx.MakeContractTx(sk, common.HexToAddress(Contracts.Credit), data)
+}
@@ -0,0 +1,168 @@
+package tx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// abi and address of a contract by name: registry, market or credit
+func ContractByName(name string) (abi.ABI, common.Address, error) {
+	var js, addr string
+	switch strings.ToLower(name) {
+	case "registry":
+		js, addr = RegABI, Contracts.Registry
+	case "market":
+		js, addr = MarketABI, Contracts.Market
+	case "credit":
+		js, addr = CreditABI, Contracts.Credit
+	default:
+		return abi.ABI{}, common.Address{}, fmt.Errorf("unknown contract %q, want registry, market or credit", name)
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(js))
+	if err != nil {
+		return abi.ABI{}, common.Address{}, err
+	}
+
+	return parsed, common.HexToAddress(addr), nil
+}
+
+// sk of a role: user, provider or admin, or of an account profile
+func RoleKey(role string) (string, error) {
+	switch strings.ToLower(role) {
+	case "user":
+		return U_SK, nil
+	case "provider":
+		return P_SK, nil
+	case "admin":
+		return A_SK, nil
+	}
+
+	if a, ok := Accounts[role]; ok {
+		return a.SK()
+	}
+
+	return "", fmt.Errorf("unknown role or account %q, want user, provider, admin or one of %v", role, AccountNames())
+}
+
+// pack the data for calling method name of a contract
+func PackData(abiJSON string, name string, args ...interface{}) ([]byte, error) {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Pack(name, args...)
+}
+
+// Make a signed tx calling contract with data, sent from the owner of sk
+func (tx *Tx) MakeContractTx(sk string, to common.Address, data []byte) error {
+	SignedTx, err := MakeSignedTx(tx.c, sk, to, nil, DefaultGasLimit, data)
+	if err != nil {
+		return err
+	}
+
+	// marshal tx into json
+	js, err := SignedTx.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	tx.SignedTx = SignedTx
+	tx.JsonTx = js
+
+	return nil
+}
+
+// call any method of a contract with string args parsed by its abi.
+// view and pure methods are run with eth_call and their outputs returned,
+// other methods are signed by role into tx.SignedTx and nil is returned.
+func (tx *Tx) Call(contract, method string, args []string, role string) ([]interface{}, error) {
+	parsed, to, err := ContractByName(contract)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := parsed.Methods[method]
+	if !ok {
+		return nil, fmt.Errorf("%s has no method %q", contract, method)
+	}
+
+	vals, err := ParseArgs(m.Inputs, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", contract, method, err)
+	}
+
+	input, err := m.Inputs.Pack(vals...)
+	if err != nil {
+		return nil, err
+	}
+	data := append(m.ID, input...)
+
+	sk, err := RoleKey(role)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.IsConstant() {
+		log.Printf("calling %s.%s", contract, m.Sig)
+
+		// call from the role's address, some views depend on msg.sender
+		from, err := KeyAddress(sk)
+		if err != nil {
+			return nil, err
+		}
+		msg := ethereum.CallMsg{From: from, To: &to, Data: data}
+
+		out, err := tx.c.CallContract(context.Background(), msg, nil)
+		if err != nil {
+			return nil, fmt.Errorf("call %s.%s: %w", contract, method, err)
+		}
+
+		return m.Outputs.Unpack(out)
+	}
+
+	if err := checkCall(strings.ToLower(contract), m, sk, vals); err != nil {
+		return nil, err
+	}
+	if err := tx.preflightCall(strings.ToLower(contract), m, sk, vals); err != nil {
+		return nil, err
+	}
+
+	log.Printf("making signed %s.%s tx as %s", contract, m.Sig, role)
+
+	return nil, tx.MakeContractTx(sk, to, data)
+}
+
+// find the contract and method of calldata sent to one of the contracts
+func DecodeCall(to common.Address, data []byte) (string, *abi.Method, []interface{}, error) {
+	name := ContractName(to)
+	if name == "" {
+		return "", nil, nil, fmt.Errorf("%s is not one of the contracts", to)
+	}
+	if len(data) < 4 {
+		return name, nil, nil, fmt.Errorf("no method id in calldata")
+	}
+
+	parsed, _, err := ContractByName(name)
+	if err != nil {
+		return name, nil, nil, err
+	}
+	m, err := parsed.MethodById(data[:4])
+	if err != nil {
+		return name, nil, nil, err
+	}
+
+	args, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return name, m, nil, fmt.Errorf("%s.%s: %w", name, m.Name, err)
+	}
+
+	return name, m, args, nil
+}
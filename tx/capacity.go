@@ -0,0 +1,115 @@
+package tx
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/go/registry"
+)
+
+// the capacity of a cp as updatecp sends it, totals and usage
+type Capacity struct {
+	NNode uint64 `json:"nNode"`
+	UNode uint64 `json:"uNode"`
+	NMem  uint64 `json:"nMem"`
+	UMem  uint64 `json:"uMem"`
+	NDisk uint64 `json:"nDisk"`
+	UDisk uint64 `json:"uDisk"`
+}
+
+// the capacity the cp has on chain
+func CPCapacity(cp *registry.IRegistryCP) Capacity {
+	return Capacity{cp.NNode, cp.UNode, cp.NMem, cp.UMem, cp.NDisk, cp.UDisk}
+}
+
+// set the capacity of the cp info
+func (c Capacity) Apply(cp *registry.IRegistryCP) {
+	cp.NNode, cp.UNode = c.NNode, c.UNode
+	cp.NMem, cp.UMem = c.NMem, c.UMem
+	cp.NDisk, cp.UDisk = c.NDisk, c.UDisk
+}
+
+// the values changed from old: totals on any change, usage when it moved more than
+// threshold percent of its total
+func (c Capacity) Changes(old Capacity, threshold float64) []string {
+	var changed []string
+	for _, f := range []struct {
+		name, usedName  string
+		total, oldTotal uint64
+		used, oldUsed   uint64
+	}{
+		{"nNode", "uNode", c.NNode, old.NNode, c.UNode, old.UNode},
+		{"nMem", "uMem", c.NMem, old.NMem, c.UMem, old.UMem},
+		{"nDisk", "uDisk", c.NDisk, old.NDisk, c.UDisk, old.UDisk},
+	} {
+		if f.total != f.oldTotal {
+			changed = append(changed, fmt.Sprintf("%s %d -> %d", f.name, f.oldTotal, f.total))
+		}
+		diff := f.used - f.oldUsed
+		if f.used < f.oldUsed {
+			diff = f.oldUsed - f.used
+		}
+		if diff == 0 {
+			continue
+		}
+		if f.total == 0 || float64(diff)*100 > threshold*float64(f.total) {
+			changed = append(changed, fmt.Sprintf("%s %d -> %d", f.usedName, f.oldUsed, f.used))
+		}
+	}
+
+	return changed
+}
+
+// the registered nodes of a cp, read by id from 1 until gap ids in a row are missing
+func (tx *Tx) CPNodes(cp common.Address, gap int) ([]*registry.IRegistryNode, error) {
+	var nodes []*registry.IRegistryNode
+	for id, missing := uint64(1), 0; missing < gap; id++ {
+		node, err := tx.GetNode(cp, id)
+		if err != nil && !IsRevert(err) {
+			return nil, err
+		}
+		if err != nil || node.Cp != cp {
+			missing++
+			continue
+		}
+		missing = 0
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// the totals of the nodes: their number, memory and disk
+func NodeTotals(nodes []*registry.IRegistryNode) Capacity {
+	c := Capacity{NNode: uint64(len(nodes))}
+	for _, n := range nodes {
+		c.NMem += n.Mem.Num
+		c.NDisk += n.Disk.Num
+	}
+
+	return c
+}
+
+// the ids of the cp's nodes with an order on chain, the users are those of the index's orders
+func (tx *Tx) UsedNodes(ix *Index, cp common.Address) (map[uint64]bool, error) {
+	orders, err := ix.Orders(nil, &cp, "")
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[uint64]bool{}
+	for _, o := range orders {
+		order, err := tx.GetOrder(o.User, cp)
+		if IsRevert(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if order.Provider == cp {
+			used[order.NodeId] = true
+		}
+	}
+
+	return used, nil
+}
@@ -0,0 +1,29 @@
+package tx
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapacityChanges(t *testing.T) {
+	old := Capacity{NNode: 4, UNode: 1, NMem: 100, UMem: 50, NDisk: 1000, UDisk: 200}
+
+	for _, tc := range []struct {
+		name      string
+		now       Capacity
+		threshold float64
+		want      []string
+	}{
+		{"unchanged", old, 5, nil},
+		{"usage within the threshold", Capacity{4, 1, 100, 54, 1000, 249}, 5, nil},
+		{"usage past the threshold", Capacity{4, 1, 100, 44, 1000, 251}, 5, []string{"uMem 50 -> 44", "uDisk 200 -> 251"}},
+		{"any usage move at 0", Capacity{4, 1, 100, 51, 1000, 200}, 0, []string{"uMem 50 -> 51"}},
+		{"totals on any change", Capacity{5, 1, 101, 50, 1000, 200}, 50, []string{"nNode 4 -> 5", "nMem 100 -> 101"}},
+		{"total dropping to 0", Capacity{0, 1, 100, 50, 1000, 200}, 5, []string{"nNode 4 -> 0"}},
+		{"usage of a zero total on any move", Capacity{0, 2, 100, 50, 1000, 200}, 50, []string{"nNode 4 -> 0", "uNode 1 -> 2"}},
+	} {
+		if got := tc.now.Changes(old, tc.threshold); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: changes %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package tx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/grid/contracts/eth"
+	"github.com/grid/contracts/eth/contracts"
+)
+
+// default config file of the chains, see chains.example.json
+var ChainsPath = "chains.json"
+
+// gas limit of contract calls, unless the chain's gas policy sets one
+const baseGasLimit = 1000000
+
+// gas limit of contract calls on the chain in use
+var DefaultGasLimit uint64 = baseGasLimit
+
+// how txs on a chain pay for gas, prices are like 20 gwei or wei:20000000000
+type GasPolicy struct {
+	// gas limit of contract calls
+	GasLimit uint64 `json:"gasLimit,omitempty"`
+	// fixed gas price instead of the suggested one
+	GasPrice string `json:"gasPrice,omitempty"`
+	// refuse to sign above this gas price
+	MaxGasPrice string `json:"maxGasPrice,omitempty"`
+}
+
+// contract addresses given inline in a chain
+type Addresses struct {
+	Credit   string `json:"credit,omitempty"`
+	Registry string `json:"registry,omitempty"`
+	Market   string `json:"market,omitempty"`
+}
+
+// a named set of contracts on a chain, e.g. an old and a new market during a migration
+type Deployment struct {
+	// contracts address file, replaces the chain's addresses
+	ContractsFile string `json:"contractsFile,omitempty"`
+	// inline addresses, override the ones in the file or the chain's
+	Contracts Addresses `json:"contracts"`
+	// abis of the deployment's version, the chain's when empty
+	ABIDir string `json:"abiDir,omitempty"`
+}
+
+// a named chain to send txs to
+type Chain struct {
+	Name string `json:"-"`
+	// the selected deployment, empty for the chain's own contracts
+	Deployment string `json:"-"`
+
+	RPC string `json:"rpc"`
+	// expected chain id, 0 to skip the check
+	ChainID uint64 `json:"chainId,omitempty"`
+
+	// contracts address file, in the format contracts.Local.LoadPath reads
+	ContractsFile string `json:"contractsFile,omitempty"`
+	// inline addresses, override the ones in the file
+	Contracts Addresses `json:"contracts"`
+
+	// dir with registry/Registry.abi, market/Market.abi and credit/Credit.abi
+	ABIDir string `json:"abiDir,omitempty"`
+
+	Gas GasPolicy `json:"gas"`
+
+	// base url of a block explorer, e.g. https://sepolia.etherscan.io
+	Explorer string `json:"explorer,omitempty"`
+
+	// open, confirm or protected, how sends to the chain are reviewed
+	Safety string `json:"safety,omitempty"`
+	// operations allowed on the chain, like market.createOrder, market.* or fund, all when empty
+	AllowedOps []string `json:"allowedOps,omitempty"`
+
+	// named deployments on top of the chain's contracts, selected with -deployment
+	Deployments map[string]*Deployment `json:"deployments,omitempty"`
+	// deployment used when none is selected, the chain's own contracts when empty
+	DefaultDeployment string `json:"defaultDeployment,omitempty"`
+}
+
+// safety levels of a chain
+const (
+	// send without review
+	SafetyOpen = "open"
+	// review the tx and answer yes
+	SafetyConfirm = "confirm"
+	// review the tx and type the chain name
+	SafetyProtected = "protected"
+)
+
+// the chains known without a config file
+func builtinChains() map[string]*Chain {
+	return map[string]*Chain{
+		"local": {RPC: eth.Ganache, ChainID: 1337, ContractsFile: "../grid-contracts/eth/contracts/local.json"},
+		"sepo": {
+			RPC:           eth.Sepolia,
+			ChainID:       11155111,
+			ContractsFile: "../grid-contracts/eth/contracts/sepo.json",
+			Explorer:      "https://sepolia.etherscan.io",
+			Safety:        SafetyProtected,
+		},
+		// their ids are deployment specific, a chains config gives them before anything is signed
+		"dev":  {RPC: eth.DevChain, ContractsFile: "../grid-contracts/eth/contracts/dev.json"},
+		"test": {RPC: eth.TestChain, ContractsFile: "../grid-contracts/eth/contracts/test.json"},
+	}
+}
+
+// read the chains config at path on top of the builtin chains,
+// a missing file at the default path only gives the builtins
+func LoadChains(path string) (map[string]*Chain, error) {
+	chains := builtinChains()
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) && path == ChainsPath {
+		return named(chains), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg struct {
+		Chains map[string]*Chain `json:"chains"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// relative files are relative to the config file
+	dir := filepath.Dir(path)
+	for name, c := range cfg.Chains {
+		if c.RPC == "" {
+			return nil, fmt.Errorf("%s: chain %s has no rpc", path, name)
+		}
+		if c.ContractsFile != "" && !filepath.IsAbs(c.ContractsFile) {
+			c.ContractsFile = filepath.Join(dir, c.ContractsFile)
+		}
+		if c.ABIDir != "" && !filepath.IsAbs(c.ABIDir) {
+			c.ABIDir = filepath.Join(dir, c.ABIDir)
+		}
+		for _, a := range []string{c.Contracts.Credit, c.Contracts.Registry, c.Contracts.Market} {
+			if a != "" && !common.IsHexAddress(a) {
+				return nil, fmt.Errorf("%s: chain %s has a bad address %q", path, name, a)
+			}
+		}
+		for dname, d := range c.Deployments {
+			if d.ContractsFile != "" && !filepath.IsAbs(d.ContractsFile) {
+				d.ContractsFile = filepath.Join(dir, d.ContractsFile)
+			}
+			if d.ABIDir != "" && !filepath.IsAbs(d.ABIDir) {
+				d.ABIDir = filepath.Join(dir, d.ABIDir)
+			}
+			for _, a := range []string{d.Contracts.Credit, d.Contracts.Registry, d.Contracts.Market} {
+				if a != "" && !common.IsHexAddress(a) {
+					return nil, fmt.Errorf("%s: deployment %s of chain %s has a bad address %q", path, dname, name, a)
+				}
+			}
+		}
+		if _, ok := c.Deployments[c.DefaultDeployment]; c.DefaultDeployment != "" && !ok {
+			return nil, fmt.Errorf("%s: chain %s has no deployment %q for its default", path, name, c.DefaultDeployment)
+		}
+		switch c.Safety {
+		case "", SafetyOpen, SafetyConfirm, SafetyProtected:
+		default:
+			return nil, fmt.Errorf("%s: chain %s has safety %q, want open, confirm or protected", path, name, c.Safety)
+		}
+		for _, op := range c.AllowedOps {
+			if _, err := pathpkg.Match(op, ""); err != nil {
+				return nil, fmt.Errorf("%s: chain %s has a bad allowed op %q: %w", path, name, op, err)
+			}
+		}
+		chains[name] = c
+	}
+
+	return named(chains), nil
+}
+
+func named(chains map[string]*Chain) map[string]*Chain {
+	for name, c := range chains {
+		c.Name = name
+	}
+	return chains
+}
+
+// names of the chains, sorted
+func ChainNames(chains map[string]*Chain) []string {
+	names := make([]string, 0, len(chains))
+	for name := range chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// names of the chain's deployments, sorted
+func (c *Chain) DeploymentNames() []string {
+	names := make([]string, 0, len(c.Deployments))
+	for name := range c.Deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// a copy of the chain using the named deployment, or its default one when name is empty
+func (c *Chain) Select(name string) (*Chain, error) {
+	if name == "" {
+		name = c.DefaultDeployment
+	}
+	sel := *c
+	if name == "" {
+		return &sel, nil
+	}
+
+	d, ok := c.Deployments[name]
+	if !ok {
+		return nil, fmt.Errorf("chain %s has no deployment %q, want one of %v", c.Name, name, c.DeploymentNames())
+	}
+	sel.Deployment = name
+
+	// a deployment's own file replaces all of the chain's addresses
+	if d.ContractsFile != "" {
+		sel.ContractsFile = d.ContractsFile
+		sel.Contracts = Addresses{}
+	}
+	if d.Contracts.Credit != "" {
+		sel.Contracts.Credit = d.Contracts.Credit
+	}
+	if d.Contracts.Registry != "" {
+		sel.Contracts.Registry = d.Contracts.Registry
+	}
+	if d.Contracts.Market != "" {
+		sel.Contracts.Market = d.Contracts.Market
+	}
+	if d.ABIDir != "" {
+		sel.ABIDir = d.ABIDir
+	}
+
+	return &sel, nil
+}
+
+// the chain's name with its deployment, e.g. dev/v2
+func (c *Chain) Label() string {
+	if c.Deployment == "" {
+		return c.Name
+	}
+
+	return c.Name + "/" + c.Deployment
+}
+
+// load the chain's abis, contract addresses and gas policy into the package
+func (c *Chain) Use() error {
+	if err := LoadABIs(c.ABIDir); err != nil {
+		return err
+	}
+
+	cs := contracts.Contracts{}
+	if c.ContractsFile != "" {
+		local := contracts.Local{}
+		if err := local.LoadPath(c.ContractsFile); err != nil {
+			return fmt.Errorf("load contracts of %s: %w", c.Label(), err)
+		}
+		cs = local.Contracts
+	}
+
+	// inline addresses win
+	if c.Contracts.Credit != "" {
+		cs.Credit = c.Contracts.Credit
+	}
+	if c.Contracts.Registry != "" {
+		cs.Registry = c.Contracts.Registry
+	}
+	if c.Contracts.Market != "" {
+		cs.Market = c.Contracts.Market
+	}
+	if cs.Credit == "" || cs.Registry == "" || cs.Market == "" {
+		return fmt.Errorf("chain %s is missing contract addresses: %v", c.Label(), cs)
+	}
+	Contracts = cs
+	signChain, signChainID = c.Label(), c.ChainID
+
+	return c.Gas.use()
+}
+
+// the chain in use and its expected id, txs are only signed for it
+var (
+	signChain   string
+	signChainID uint64
+)
+
+// the chain id to sign for: the expected id of the chain in use, which the rpc must have
+func SigningChainID(client *ethclient.Client) (*big.Int, error) {
+	if signChainID == 0 {
+		return nil, invalid("chain %s has no chainId in the chains config, it is required to sign", signChain)
+	}
+
+	id, err := client.ChainID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if id.Cmp(new(big.Int).SetUint64(signChainID)) != 0 {
+		return nil, invalid("rpc is chain %s, but chain %s expects %d", id, signChain, signChainID)
+	}
+
+	return id, nil
+}
+
+// set the gas policy used when signing
+func (g GasPolicy) use() error {
+	// nothing is kept from the chain or deployment used before
+	DefaultGasLimit, gasPrice, maxGasPrice = baseGasLimit, nil, nil
+	if g.GasLimit != 0 {
+		DefaultGasLimit = g.GasLimit
+	}
+
+	var err error
+	if g.GasPrice != "" {
+		if gasPrice, err = ParseInt(g.GasPrice); err != nil {
+			return fmt.Errorf("gas price: %w", err)
+		}
+	}
+	if g.MaxGasPrice != "" {
+		if maxGasPrice, err = ParseInt(g.MaxGasPrice); err != nil {
+			return fmt.Errorf("max gas price: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// gas price settings of the chain in use, nil when not set
+var gasPrice, maxGasPrice *big.Int
+
+// the safety level of the chain, open when not set
+func (c *Chain) SafetyLevel() string {
+	if c.Safety == "" {
+		return SafetyOpen
+	}
+
+	return c.Safety
+}
+
+// is the operation allowed on the chain, ops are matched like path.Match
+func (c *Chain) Allows(op string) bool {
+	if len(c.AllowedOps) == 0 {
+		return true
+	}
+	for _, pattern := range c.AllowedOps {
+		if ok, _ := pathpkg.Match(pattern, op); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// link to a tx on the chain's explorer, empty without one
+func (c *Chain) TxURL(hash string) string {
+	if c.Explorer == "" {
+		return ""
+	}
+
+	return c.Explorer + "/tx/" + hash
+}
+
+// refuse a chain whose id is not the expected one
+func (tx *Tx) CheckChainID(c *Chain) error {
+	if c.ChainID == 0 {
+		log.Printf("warning: chain %s has no expected chainId, not checking the rpc's, signing is refused", c.Name)
+		return nil
+	}
+
+	id, err := tx.ChainID()
+	if err != nil {
+		return err
+	}
+	if id.Cmp(new(big.Int).SetUint64(c.ChainID)) != 0 {
+		return invalid("rpc %s is chain %s, but chain %s expects %d", c.RPC, id, c.Name, c.ChainID)
+	}
+
+	return nil
+}
+
+// refuse a chain whose id is not the expected one, or without code at the contract addresses
+func (tx *Tx) CheckChain(c *Chain) error {
+	if err := tx.CheckChainID(c); err != nil {
+		return err
+	}
+
+	for _, cc := range []struct{ name, addr string }{
+		{"credit", Contracts.Credit},
+		{"registry", Contracts.Registry},
+		{"market", Contracts.Market},
+	} {
+		code, err := tx.c.CodeAt(context.Background(), common.HexToAddress(cc.addr), nil)
+		if err != nil {
+			return err
+		}
+		if len(code) == 0 {
+			return fmt.Errorf("no code at %s address %s on chain %s, is its contracts file stale?", cc.name, cc.addr, c.Label())
+		}
+	}
+
+	return nil
+}
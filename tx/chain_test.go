@@ -0,0 +1,114 @@
+package tx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChainSelect(t *testing.T) {
+	c := &Chain{
+		Name:          "dev",
+		ContractsFile: "dev.json",
+		Contracts:     Addresses{Credit: "0xc1", Registry: "0xr1", Market: "0xm1"},
+		ABIDir:        "abi",
+		Deployments: map[string]*Deployment{
+			"v1": {Contracts: Addresses{Market: "0xm2"}},
+			"v2": {ContractsFile: "v2.json", Contracts: Addresses{Registry: "0xr3"}, ABIDir: "abi/v2"},
+		},
+		DefaultDeployment: "v1",
+	}
+
+	for _, tc := range []struct {
+		name, label, file, abiDir string
+		contracts                 Addresses
+	}{
+		// the default deployment overrides the market only
+		{"", "dev/v1", "dev.json", "abi", Addresses{"0xc1", "0xr1", "0xm2"}},
+		// its own file replaces all of the chain's addresses
+		{"v2", "dev/v2", "v2.json", "abi/v2", Addresses{Registry: "0xr3"}},
+	} {
+		sel, err := c.Select(tc.name)
+		if err != nil {
+			t.Fatalf("select %q: %v", tc.name, err)
+		}
+		if sel.Label() != tc.label || sel.ContractsFile != tc.file || sel.ABIDir != tc.abiDir || sel.Contracts != tc.contracts {
+			t.Errorf("select %q = %s %s %s %+v, want %s %s %s %+v", tc.name, sel.Label(), sel.ContractsFile, sel.ABIDir, sel.Contracts,
+				tc.label, tc.file, tc.abiDir, tc.contracts)
+		}
+	}
+	if c.Deployment != "" || c.Contracts.Market != "0xm1" {
+		t.Errorf("select changed the chain: %s %+v", c.Deployment, c.Contracts)
+	}
+
+	if _, err := c.Select("v3"); err == nil {
+		t.Error("selected a missing deployment")
+	}
+	c.DefaultDeployment = ""
+	if sel, err := c.Select(""); err != nil || sel.Label() != "dev" {
+		t.Errorf("select without a default = %v, %v, want the chain's own contracts", sel, err)
+	}
+}
+
+func TestChainAllows(t *testing.T) {
+	open := &Chain{Name: "local"}
+	if !open.Allows("market.createOrder") {
+		t.Error("a chain without allowed ops refused one")
+	}
+
+	c := &Chain{Name: "prod", AllowedOps: []string{"market.*", "credit.approve"}}
+	for op, want := range map[string]bool{
+		"market.createOrder": true,
+		"credit.approve":     true,
+		"credit.transfer":    false,
+		"registry.register":  false,
+		"fund":               false,
+	} {
+		if got := c.Allows(op); got != want {
+			t.Errorf("allows %s = %v, want %v", op, got, want)
+		}
+	}
+}
+
+// a chain's gas policy is not kept when another chain is used
+func TestGasPolicyUse(t *testing.T) {
+	defer GasPolicy{}.use()
+
+	if err := (GasPolicy{GasLimit: 300000, GasPrice: "2 gwei", MaxGasPrice: "20 gwei"}).use(); err != nil {
+		t.Fatal(err)
+	}
+	if DefaultGasLimit != 300000 || gasPrice == nil || maxGasPrice == nil {
+		t.Fatalf("policy in use: gas limit %d, price %v, max %v", DefaultGasLimit, gasPrice, maxGasPrice)
+	}
+
+	if err := (GasPolicy{}).use(); err != nil {
+		t.Fatal(err)
+	}
+	if DefaultGasLimit != baseGasLimit || gasPrice != nil || maxGasPrice != nil {
+		t.Errorf("after an empty policy: gas limit %d, price %v, max %v, want %d and none", DefaultGasLimit, gasPrice, maxGasPrice, baseGasLimit)
+	}
+}
+
+func TestLoadChainsAddresses(t *testing.T) {
+	good := "0x00000000000000000000000000000000000000c1"
+	for _, tc := range []struct {
+		name, config string
+		ok           bool
+	}{
+		{"inline addresses", `{"chains": {"dev": {"rpc": "http://dev", "contracts": {"credit": "` + good + `"}}}}`, true},
+		{"typo in a chain address", `{"chains": {"dev": {"rpc": "http://dev", "contracts": {"credit": "0x00c1"}}}}`, false},
+		{"typo in a deployment address", `{"chains": {"dev": {"rpc": "http://dev", "deployments": {"v1": {"contracts": {"market": "0xzz"}}}}}}`, false},
+	} {
+		path := filepath.Join(t.TempDir(), "chains.json")
+		if err := os.WriteFile(path, []byte(tc.config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		chains, err := LoadChains(path)
+		if tc.ok && (err != nil || chains["dev"].Contracts.Credit != good) {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("%s: loaded", tc.name)
+		}
+	}
+}
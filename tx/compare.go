@@ -0,0 +1,59 @@
+package tx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// a record as one deployment sees it, e.g. a cp or an order
+type Snapshot struct {
+	Deployment string `json:"deployment"`
+	// the view reverted, the record is not on the deployment
+	Missing bool `json:"missing,omitempty"`
+	// the view cannot be read on the deployment, e.g. its abi version has no such method
+	Problem string `json:"problem,omitempty"`
+	// outputs of the view by name
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// read a view of a contract in use, the contracts and abis of the deployment must be loaded
+func (tx *Tx) Snapshot(deployment, contract, method string, args ...interface{}) (*Snapshot, error) {
+	s := &Snapshot{Deployment: deployment}
+
+	parsed, to, err := ContractByName(contract)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := parsed.Methods[method]
+	if !ok {
+		s.Problem = fmt.Sprintf("abi has no %s.%s", contract, method)
+		return s, nil
+	}
+
+	// the args of the method may differ between abi versions
+	input, err := parsed.Pack(method, args...)
+	if err != nil {
+		s.Problem = err.Error()
+		return s, nil
+	}
+
+	out, err := tx.c.CallContract(context.Background(), ethereum.CallMsg{To: &to, Data: input}, nil)
+	if IsRevert(err) {
+		s.Missing = true
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("call %s on %s: %w", method, deployment, err)
+	}
+
+	vals, err := m.Outputs.Unpack(out)
+	if err != nil {
+		s.Problem = err.Error()
+		return s, nil
+	}
+	s.Fields = JSONValues(OutputFields(m.Outputs, vals))
+
+	return s, nil
+}
@@ -0,0 +1,141 @@
+package tx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// prefix of a raw base unit amount
+const WeiPrefix = "wei:"
+
+// max value of an uint256
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// erc20 metadata needed to read and print amounts
+type Token struct {
+	Symbol   string
+	Decimals uint8
+}
+
+// the credit token, loaded from chain with LoadCredit
+var Credit = Token{Symbol: "CRD", Decimals: 18}
+
+// cache of token metadata, keyed by chain id and token address
+var TokenCachePath = filepath.Join(cacheDir(), "sendtx", "tokens.json")
+
+func cacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
+
+// parse an amount like 40, 40.5 CRD or wei:40000000 into base units
+func (t Token) Parse(s string) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+
+	var v *big.Int
+	if raw, ok := strings.CutPrefix(s, WeiPrefix); ok {
+		n, ok := new(big.Int).SetString(strings.TrimSpace(raw), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid base unit amount %q", s)
+		}
+		v = n
+	} else {
+		// an optional symbol after the number must be ours
+		num, sym, found := strings.Cut(s, " ")
+		if found && !strings.EqualFold(strings.TrimSpace(sym), t.Symbol) {
+			return nil, fmt.Errorf("amount %q is not in %s", s, t.Symbol)
+		}
+
+		n, err := ParseCredit(num, t.Decimals)
+		if err != nil {
+			return nil, err
+		}
+		v = n
+	}
+
+	if v.Sign() < 0 {
+		return nil, fmt.Errorf("negative amount %q", s)
+	}
+	if v.Cmp(maxUint256) > 0 {
+		return nil, fmt.Errorf("amount %q overflows uint256", s)
+	}
+
+	return v, nil
+}
+
+// print an amount in both token units and base units
+func (t Token) Format(v *big.Int) string {
+	if v == nil {
+		v = new(big.Int)
+	}
+	return fmt.Sprintf("%s %s (%s%s)", FormatCredit(v, t.Decimals), t.Symbol, WeiPrefix, v)
+}
+
+// read decimals and symbol of the credit contract, cached per chain
+func (tx *Tx) LoadCredit() error {
+	chainID, err := tx.ChainID()
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s/%s", chainID, common.HexToAddress(Contracts.Credit).Hex())
+
+	cache := map[string]Token{}
+	if b, err := os.ReadFile(TokenCachePath); err == nil {
+		if err := json.Unmarshal(b, &cache); err != nil {
+			log.Printf("ignoring bad token cache %s: %v", TokenCachePath, err)
+		}
+	}
+
+	if t, ok := cache[key]; ok {
+		Credit = t
+		return nil
+	}
+
+	to := common.HexToAddress(Contracts.Credit)
+
+	out, err := tx.CallView(CreditABI, to, "decimals")
+	if err != nil {
+		return err
+	}
+	decimals, ok := out[0].(uint8)
+	if !ok {
+		return fmt.Errorf("unexpected decimals type %T", out[0])
+	}
+
+	out, err = tx.CallView(CreditABI, to, "symbol")
+	if err != nil {
+		return err
+	}
+	symbol, ok := out[0].(string)
+	if !ok {
+		return fmt.Errorf("unexpected symbol type %T", out[0])
+	}
+
+	Credit = Token{Symbol: symbol, Decimals: decimals}
+
+	// save the cache, a failure only costs a chain read next time
+	cache[key] = Credit
+	b, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(TokenCachePath), 0o755); err != nil {
+		log.Printf("cannot save token cache: %v", err)
+		return nil
+	}
+	if err := os.WriteFile(TokenCachePath, b, 0o644); err != nil {
+		log.Printf("cannot save token cache: %v", err)
+	}
+
+	return nil
+}
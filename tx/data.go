@@ -1,17 +1,13 @@
 package tx
 
 import (
-	"fmt"
-	"log"
 	"math/big"
 	"os"
-	"strings"
+	"path/filepath"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/grid/contracts/eth"
 	"github.com/grid/contracts/eth/contracts"
-	"github.com/grid/contracts/go/market"
 	"github.com/grid/contracts/go/registry"
 )
 
@@ -53,171 +49,59 @@ var (
 	CRE_ABI_PATH = "../grid-contracts/abi/credit/Credit.abi"
 )
 
-// read ABI from file
-func init() {
+// read the ABIs from dir, or from the default paths when dir is empty
+func LoadABIs(dir string) error {
+	regPath, marPath, crePath := REG_ABI_PATH, MAR_ABI_PATH, CRE_ABI_PATH
+	if dir != "" {
+		regPath = filepath.Join(dir, "registry", "Registry.abi")
+		marPath = filepath.Join(dir, "market", "Market.abi")
+		crePath = filepath.Join(dir, "credit", "Credit.abi")
+	}
+
 	// read registry abi from file
-	_RegABI, err := os.ReadFile(REG_ABI_PATH)
+	_RegABI, err := os.ReadFile(regPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// read abi from file
-	_MarketABI, err := os.ReadFile(MAR_ABI_PATH)
+	_MarketABI, err := os.ReadFile(marPath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// read abi from file
-	_CreditABI, err := os.ReadFile(CRE_ABI_PATH)
+	_CreditABI, err := os.ReadFile(crePath)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	// local to global
 	RegABI = string(_RegABI)
 	MarketABI = string(_MarketABI)
 	CreditABI = string(_CreditABI)
-}
-
-// the tx data for calling registry.register
-func RegisterData() []byte {
-	// abi
-	registryABI, err := abi.JSON(strings.NewReader(RegABI))
-	if err != nil {
-		panic(err)
-	}
 
-	// func name
-	functionName := "register"
-	//value := big.NewInt(0)
-
-	// make a cp
-	info, err := newCP()
-	if err != nil {
-		panic(err)
-	}
-
-	// pack params
-	method := registryABI.Methods[functionName]
-	//input, err := method.Inputs.Pack("a", "b", "c", uint64(10), uint64(20), uint64(40), uint64(807), uint64(33), uint64(33), uint64(33), uint64(33))
-	input, err := method.Inputs.Pack(info)
-	if err != nil {
-		panic(err)
-	}
-
-	// 构造完整的data字段
-	data := append(method.ID, input...)
-	//fmt.Printf("Data: %x\n", data)
-
-	return data
+	return nil
 }
 
-// the tx data for calling registry.register
-func UpdateCpData() []byte {
-	// abi
-	registryABI, err := abi.JSON(strings.NewReader(RegABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// func name
-	functionName := "updatecp"
-	//value := big.NewInt(0)
-
-	// make a cp
-	info, err := newCP()
-	if err != nil {
-		panic(err)
-	}
-
-	// pack params
-	method := registryABI.Methods[functionName]
-	//input, err := method.Inputs.Pack("a", "b", "c", uint64(10), uint64(20), uint64(40), uint64(807), uint64(33), uint64(33), uint64(33), uint64(33))
-	input, err := method.Inputs.Pack(info)
-	if err != nil {
-		panic(err)
-	}
-
-	// 构造完整的data字段
-	data := append(method.ID, input...)
-	//fmt.Printf("Data: %x\n", data)
-
-	return data
-}
-
-// generate a test cp
-func newCP() (*registry.IRegistryCP, error) {
+// a cp of the configured provider
+func NewCP(name, ip, domain, port string) *registry.IRegistryCP {
 	// the register cp info
 	info := registry.IRegistryCP{
-		Addr:   common.HexToAddress("0xEf95c72C836605203F7f66788E450Af2a4141957"),
-		Name:   "cp1",
-		Ip:     "183.240.197.189",
-		Domain: "testdomain",
-		Port:   "41234",
-
-		NNode: 0,
-		UNode: 0,
-		NMem:  0,
-		UMem:  0,
-		NDisk: 0,
-		UDisk: 0,
+		Addr:   common.HexToAddress(P_ADDR),
+		Name:   name,
+		Ip:     ip,
+		Domain: domain,
+		Port:   port,
 	}
 
-	return &info, nil
-}
-
-// for revise
-func newCP2() (*registry.IRegistryCP, error) {
-	// the register cp info
-	info := registry.IRegistryCP{
-		Addr:   common.HexToAddress("0xEf95c72C836605203F7f66788E450Af2a4141957"),
-		Name:   "revised name",
-		Ip:     "revise ip",
-		Domain: "revised domain",
-		Port:   "revised port",
-
-		NNode: 0,
-		UNode: 0,
-		NMem:  0,
-		UMem:  0,
-		NDisk: 0,
-		UDisk: 0,
-	}
-
-	return &info, nil
-}
-
-// the tx data for call add_node
-func AddNodeData(node *registry.IRegistryNode) []byte {
-	// registry abi
-	registryABI, err := abi.JSON(strings.NewReader(RegABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// func name
-	functionName := "add_node"
-	//value := big.NewInt(0)
-
-	// pack params
-	method := registryABI.Methods[functionName]
-	//input, err := method.Inputs.Pack("a", "b", "c", uint64(10), uint64(20), uint64(40), uint64(807), uint64(33), uint64(33), uint64(33), uint64(33))
-	input, err := method.Inputs.Pack(node)
-	if err != nil {
-		panic(err)
-	}
-
-	// 构造完整的data字段
-	data := append(method.ID, input...)
-	//fmt.Printf("Data: %x\n", data)
-
-	return data
+	return &info
 }
 
 func NewNode() (*registry.IRegistryNode, error) {
 	// the register cp info
 	info := registry.IRegistryNode{
-		Cp: eth.Addr2,
+		Cp: common.HexToAddress(P_ADDR),
 		Id: 0,
 
 		Cpu: registry.IRegistryCPU{
@@ -249,7 +133,7 @@ func NewNode() (*registry.IRegistryNode, error) {
 func NewNode2() (*registry.IRegistryNode, error) {
 	// the register cp info
 	info := registry.IRegistryNode{
-		Cp: eth.Addr2,
+		Cp: common.HexToAddress(P_ADDR),
 		Id: 0,
 
 		Cpu: registry.IRegistryCPU{
@@ -276,178 +160,3 @@ func NewNode2() (*registry.IRegistryNode, error) {
 
 	return &info, nil
 }
-
-// the tx data for calling credit.approve
-//
-//	function approve(address spender, uint256 amount) public virtual override returns (bool) {
-func ApproveData() []byte {
-	// contract abi
-	creditABI, err := abi.JSON(strings.NewReader(CreditABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// function to be called
-	functionName := "approve"
-
-	// method with name
-	method := creditABI.Methods[functionName]
-
-	// set the amount to approve to market contract
-	//amount, ok := new(big.Int).SetString("262695400", 10)
-	amount, ok := new(big.Int).SetString("40000000", 10)
-	if !ok {
-		panic(fmt.Errorf("big set string failed"))
-	}
-
-	// input for calling method
-	input, err := method.Inputs.Pack(common.HexToAddress(Contracts.Market), amount)
-	if err != nil {
-		panic(err)
-	}
-
-	// 构造完整的data字段
-	data := append(method.ID, input...)
-
-	return data
-}
-
-// the tx data for calling market.createorder
-func CreateOrderData() []byte {
-	// 假设我们有一个已编译的合约ABI
-	marketABI, err := abi.JSON(strings.NewReader(MarketABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// 我们要调用的函数和参数
-	functionName := "createOrder"
-
-	// 构造调用函数和参数的方法和输入参数
-	method := marketABI.Methods[functionName]
-	order, err := newOrder()
-	if err != nil {
-		panic(err)
-	}
-
-	fmt.Println("packing")
-	// pack all params into input
-	input, err := method.Inputs.Pack(*order)
-	if err != nil {
-		panic(err)
-	}
-
-	// 构造完整的data字段
-	data := append(method.ID, input...)
-
-	return data
-}
-
-// generate a test order
-func newOrder() (*market.IMarketOrder, error) {
-	// generate an order with init data
-	totalValue, ok := new(big.Int).SetString("40000000", 10)
-	if !ok {
-		return nil, fmt.Errorf("big set string failed")
-	}
-	remu, ok := new(big.Int).SetString("0", 10)
-	if !ok {
-		return nil, fmt.Errorf("big set string failed")
-	}
-
-	// make an order
-	order := market.IMarketOrder{
-		// User:     eth.Addr1,
-		Provider: eth.Addr2,
-
-		// the cp's node selected by this order
-		NodeId: 1,
-
-		// deposit 0.01 eth
-		// TotalValue:     totalValue,
-		Remain:         totalValue,
-		Remuneration:   remu,
-		ActivateTime:   new(big.Int).SetInt64(0),
-		LastSettleTime: new(big.Int).SetInt64(0),
-		Probation:      new(big.Int).SetInt64(5),
-		Duration:       new(big.Int).SetInt64(10000000),
-		Status:         1, // unactive
-	}
-
-	return &order, nil
-}
-
-// the tx data for calling registry.revise
-func ReviseData() []byte {
-	// contract abi
-	registryABI, err := abi.JSON(strings.NewReader(RegABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// function to be called
-	functionName := "revise"
-	method := registryABI.Methods[functionName]
-
-	info, err := newCP2()
-	if err != nil {
-		panic(err)
-	}
-	// construct the input of this method
-	input, err := method.Inputs.Pack(*info)
-	if err != nil {
-		panic(err)
-	}
-
-	// the full data of tx
-	data := append(method.ID, input...)
-
-	return data
-}
-
-// tx data for user confirm
-func UserConfirmData() []byte {
-	// contract abi
-	marketABI, err := abi.JSON(strings.NewReader(MarketABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// function to be called
-	functionName := "userConfirm"
-	method := marketABI.Methods[functionName]
-
-	// construct the input of this method
-	input, err := method.Inputs.Pack(eth.Addr2)
-	if err != nil {
-		panic(err)
-	}
-
-	// the full data of tx
-	data := append(method.ID, input...)
-
-	return data
-}
-
-func UserCancelData() []byte {
-	// contract abi
-	marketABI, err := abi.JSON(strings.NewReader(MarketABI))
-	if err != nil {
-		panic(err)
-	}
-
-	// function to be called
-	functionName := "userCancel"
-	method := marketABI.Methods[functionName]
-
-	// construct the input of this method
-	input, err := method.Inputs.Pack(eth.Addr2)
-	if err != nil {
-		panic(err)
-	}
-
-	// the full data of tx
-	data := append(method.ID, input...)
-
-	return data
-}
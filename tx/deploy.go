@@ -0,0 +1,189 @@
+package tx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/grid/contracts/eth/contracts"
+)
+
+// contracts in deploy order, later constructors take earlier addresses
+var DeployOrder = []string{"Credit", "Registry", "Market"}
+
+// a compiled contract, foundry out/<Name>.sol/<Name>.json or a hardhat artifact
+type Artifact struct {
+	ABI      abi.ABI
+	Bytecode []byte
+}
+
+// read the artifact of contract name from dir
+func LoadArtifact(dir, name string) (*Artifact, error) {
+	// foundry layout first, then a flat file
+	path := filepath.Join(dir, name+".sol", name+".json")
+	if _, err := os.Stat(path); err != nil {
+		path = filepath.Join(dir, name+".json")
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode json.RawMessage `json:"bytecode"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(string(raw.ABI)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// foundry nests the code in an object, hardhat has a plain string
+	var code string
+	var obj struct {
+		Object string `json:"object"`
+	}
+	if err := json.Unmarshal(raw.Bytecode, &obj); err == nil {
+		code = obj.Object
+	} else if err := json.Unmarshal(raw.Bytecode, &code); err != nil {
+		return nil, fmt.Errorf("%s: bad bytecode: %w", path, err)
+	}
+	if !strings.HasPrefix(code, "0x") {
+		code = "0x" + code
+	}
+
+	bytecode, err := hexutil.Decode(code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: bad bytecode: %w", path, err)
+	}
+	if len(bytecode) == 0 {
+		return nil, fmt.Errorf("%s: empty bytecode, is %s abstract?", path, name)
+	}
+
+	return &Artifact{ABI: parsed, Bytecode: bytecode}, nil
+}
+
+// constructor args wired by name to the contracts deployed before
+func constructorArgs(name string, ctor abi.Method, deployed map[string]common.Address) ([]interface{}, error) {
+	var args []interface{}
+	for _, in := range ctor.Inputs {
+		if in.Type.T != abi.AddressTy {
+			return nil, fmt.Errorf("%s constructor arg %s: cannot wire type %s", name, in.Name, in.Type)
+		}
+
+		found := false
+		for dep, addr := range deployed {
+			if strings.Contains(strings.ToLower(in.Name), strings.ToLower(dep)) {
+				args = append(args, addr)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s constructor arg %s: no deployed contract matches its name", name, in.Name)
+		}
+	}
+
+	return args, nil
+}
+
+// deploy Credit, Registry and Market from the artifacts in dir, signed by sk
+func (tx *Tx) Deploy(sk string, dir string) (contracts.Contracts, error) {
+	var cs contracts.Contracts
+	deployed := map[string]common.Address{}
+
+	from, err := KeyAddress(sk)
+	if err != nil {
+		return cs, err
+	}
+
+	for _, name := range DeployOrder {
+		art, err := LoadArtifact(dir, name)
+		if err != nil {
+			return cs, err
+		}
+
+		args, err := constructorArgs(name, art.ABI.Constructor, deployed)
+		if err != nil {
+			return cs, err
+		}
+		input, err := art.ABI.Pack("", args...)
+		if err != nil {
+			return cs, err
+		}
+		code := append(append([]byte{}, art.Bytecode...), input...)
+
+		gas, err := tx.c.EstimateGas(context.Background(), ethereum.CallMsg{From: from, Data: code})
+		if err != nil {
+			return cs, fmt.Errorf("estimate %s deployment: %w", name, err)
+		}
+
+		log.Printf("deploying %s", name)
+		SignedTx, err := MakeSignedCreateTx(tx.c, sk, gas*12/10, code)
+		if err != nil {
+			return cs, err
+		}
+		tx.SignedTx = SignedTx
+		if err := tx.Send(); err != nil {
+			return cs, err
+		}
+
+		receipt, err := tx.c.TransactionReceipt(context.Background(), SignedTx.Hash())
+		if err != nil {
+			return cs, err
+		}
+
+		// code must be there
+		onchain, err := tx.c.CodeAt(context.Background(), receipt.ContractAddress, nil)
+		if err != nil {
+			return cs, err
+		}
+		if len(onchain) == 0 {
+			return cs, fmt.Errorf("no code at %s after deploying %s", receipt.ContractAddress, name)
+		}
+
+		log.Printf("%s deployed at %s", name, receipt.ContractAddress)
+		deployed[name] = receipt.ContractAddress
+	}
+
+	cs.Credit = deployed["Credit"].Hex()
+	cs.Registry = deployed["Registry"].Hex()
+	cs.Market = deployed["Market"].Hex()
+
+	return cs, nil
+}
+
+// write the addresses in the format contracts.Local.LoadPath reads, and read them back
+func WriteContracts(path string, cs contracts.Contracts) error {
+	b, err := json.MarshalIndent(cs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return err
+	}
+
+	local := contracts.Local{}
+	if err := local.LoadPath(path); err != nil {
+		return fmt.Errorf("reload %s: %w", path, err)
+	}
+	got := local.Contracts
+	if got.Credit != cs.Credit || got.Registry != cs.Registry || got.Market != cs.Market {
+		return fmt.Errorf("reload %s: got %v, want %v", path, got, cs)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package tx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// build txs without signing them, to simulate them with Simulate
+var DryRun = false
+
+// sender of the last tx built in dry-run, an unsigned tx has none to recover
+var dryRunFrom common.Address
+
+// the sender of tx.SignedTx
+func (tx *Tx) From() (common.Address, error) {
+	if tx.SignedTx == nil {
+		return common.Address{}, fmt.Errorf("no tx")
+	}
+	if DryRun {
+		return dryRunFrom, nil
+	}
+
+	return types.Sender(types.LatestSignerForChainID(tx.SignedTx.ChainId()), tx.SignedTx)
+}
+
+// what a tx would do, simulated on the latest block, amounts in wei
+type Simulation struct {
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to"`
+	Summary string          `json:"summary"`
+
+	GasLimit     uint64   `json:"gasLimit"`
+	EstimatedGas uint64   `json:"estimatedGas,omitempty"`
+	GasPrice     *big.Int `json:"gasPrice"`
+	// fee of the estimated gas up to the fee of the whole gas limit
+	MinFee *big.Int `json:"minFee"`
+	MaxFee *big.Int `json:"maxFee"`
+
+	Reverted bool   `json:"reverted"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// simulate the built tx with eth_call and estimate its gas, nothing is sent
+func (tx *Tx) Simulate() (*Simulation, error) {
+	t := tx.SignedTx
+	from, err := tx.From()
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &Simulation{
+		From:     from,
+		To:       t.To(),
+		GasLimit: t.Gas(),
+		GasPrice: t.GasPrice(),
+	}
+	if t.To() == nil {
+		sim.Summary = fmt.Sprintf("%s deploys a contract of %d bytes", who(from), len(t.Data()))
+	} else {
+		sim.Summary = Describe(from, *t.To(), t.Data())
+	}
+
+	msg := ethereum.CallMsg{From: from, To: t.To(), Gas: t.Gas(), GasPrice: t.GasPrice(), Value: t.Value(), Data: t.Data()}
+	if _, err := tx.c.CallContract(context.Background(), msg, nil); err != nil {
+		if !isCallRevert(err) {
+			return nil, err
+		}
+		sim.Reverted, sim.Reason = true, revertReason(err)
+	}
+
+	if !sim.Reverted {
+		msg.Gas = 0
+		gas, err := tx.c.EstimateGas(context.Background(), msg)
+		if err != nil {
+			if !isCallRevert(err) {
+				return nil, err
+			}
+			sim.Reverted, sim.Reason = true, revertReason(err)
+		}
+		sim.EstimatedGas = gas
+	}
+
+	// a legacy tx pays its fixed price for the gas it uses, at most its limit
+	used := sim.EstimatedGas
+	if used == 0 {
+		used = sim.GasLimit
+	}
+	sim.MinFee = new(big.Int).Mul(sim.GasPrice, new(big.Int).SetUint64(used))
+	sim.MaxFee = new(big.Int).Mul(sim.GasPrice, new(big.Int).SetUint64(sim.GasLimit))
+
+	return sim, nil
+}
+
+// the summary, gas, fee range and outcome
+func (s *Simulation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", s.Summary)
+	if s.EstimatedGas != 0 {
+		fmt.Fprintf(&b, "gas:     %d estimated, %d limit\n", s.EstimatedGas, s.GasLimit)
+	} else {
+		fmt.Fprintf(&b, "gas:     %d limit\n", s.GasLimit)
+	}
+	fmt.Fprintf(&b, "price:   %s gwei\n", FormatCredit(s.GasPrice, 9))
+	fmt.Fprintf(&b, "fee:     %s to %s ETH\n", FormatCredit(s.MinFee, 18), FormatCredit(s.MaxFee, 18))
+	if s.Reverted {
+		fmt.Fprintf(&b, "outcome: reverts: %s\n", s.Reason)
+	} else {
+		fmt.Fprintf(&b, "outcome: succeeds\n")
+	}
+	b.WriteString("dry run, nothing was signed or sent")
+
+	return b.String()
+}
+
+// a reverted call, rather than a failed rpc
+func isCallRevert(err error) bool {
+	var de rpc.DataError
+	return errors.As(err, &de) || IsRevert(err)
+}
+
+// the reason string of a reverted call, or its error
+func revertReason(err error) string {
+	var de rpc.DataError
+	if errors.As(err, &de) {
+		if s, ok := de.ErrorData().(string); ok {
+			if data, derr := hexutil.Decode(s); derr == nil {
+				if reason, uerr := abi.UnpackRevert(data); uerr == nil {
+					return reason
+				}
+			}
+		}
+	}
+
+	return err.Error()
+}
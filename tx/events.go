@@ -0,0 +1,183 @@
+package tx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// a decoded contract log
+type Event struct {
+	Contract string                 `json:"contract"`
+	Name     string                 `json:"name"`
+	Fields   map[string]interface{} `json:"fields"`
+
+	Address common.Address `json:"address"`
+	Block   uint64         `json:"block"`
+	TxHash  common.Hash    `json:"txHash"`
+	Index   uint           `json:"logIndex"`
+	Removed bool           `json:"removed,omitempty"`
+}
+
+// the event with its fields ready for json
+func (e *Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	out := event(*e)
+	out.Fields = JSONValues(e.Fields)
+
+	return json.Marshal(out)
+}
+
+// the contract name of an address in Contracts, empty if none
+func ContractName(addr common.Address) string {
+	switch addr {
+	case common.HexToAddress(Contracts.Registry):
+		return "registry"
+	case common.HexToAddress(Contracts.Market):
+		return "market"
+	case common.HexToAddress(Contracts.Credit):
+		return "credit"
+	}
+
+	return ""
+}
+
+// decode a log of one of the contracts with its abi
+func DecodeLog(l *types.Log) (*Event, error) {
+	name := ContractName(l.Address)
+	if name == "" {
+		return nil, fmt.Errorf("log of unknown contract %s", l.Address)
+	}
+	if len(l.Topics) == 0 {
+		return nil, fmt.Errorf("anonymous log of %s", name)
+	}
+
+	parsed, _, err := ContractByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ev, err := parsed.EventByID(l.Topics[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	fields := map[string]interface{}{}
+	if err := ev.Inputs.NonIndexed().UnpackIntoMap(fields, l.Data); err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", name, ev.Name, err)
+	}
+
+	var indexed abi.Arguments
+	for _, in := range ev.Inputs {
+		if in.Indexed {
+			indexed = append(indexed, in)
+		}
+	}
+	if err := abi.ParseTopicsIntoMap(fields, indexed, l.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", name, ev.Name, err)
+	}
+
+	return &Event{
+		Contract: name,
+		Name:     ev.Name,
+		Fields:   fields,
+		Address:  l.Address,
+		Block:    l.BlockNumber,
+		TxHash:   l.TxHash,
+		Index:    l.Index,
+		Removed:  l.Removed,
+	}, nil
+}
+
+// decode the logs of the contracts, skipping others
+func DecodeLogs(logs []*types.Log) []*Event {
+	var evs []*Event
+	for _, l := range logs {
+		ev, err := DecodeLog(l)
+		if err != nil {
+			continue
+		}
+		evs = append(evs, ev)
+	}
+
+	return evs
+}
+
+// receipt of the last sent tx
+func (tx *Tx) Receipt() (*types.Receipt, error) {
+	if tx.SignedTx == nil {
+		return nil, fmt.Errorf("no tx")
+	}
+
+	return tx.c.TransactionReceipt(context.Background(), tx.SignedTx.Hash())
+}
+
+// a value as a plain string: addresses and bytes in hex, ints in decimal
+func FormatValue(v interface{}) string {
+	switch e := v.(type) {
+	case common.Address:
+		return e.Hex()
+	case common.Hash:
+		return e.Hex()
+	case *big.Int:
+		return e.String()
+	case []byte:
+		return hexutil.Encode(e)
+	case [32]byte:
+		return hexutil.Encode(e[:])
+	case string:
+		return e
+	}
+
+	return fmt.Sprint(v)
+}
+
+// walk a dotted path like order.status or 0.nodeId into maps, structs and slices
+func FieldPath(v interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return v, nil
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		switch e := v.(type) {
+		case map[string]interface{}:
+			f, ok := LookupField(e, part)
+			if !ok {
+				return nil, fmt.Errorf("no field %q", part)
+			}
+			v = f
+			continue
+		}
+
+		rv := reflect.Indirect(reflect.ValueOf(v))
+		switch rv.Kind() {
+		case reflect.Struct:
+			f := rv.FieldByNameFunc(func(n string) bool {
+				return strings.EqualFold(n, part) || n == abi.ToCamelCase(part)
+			})
+			if !f.IsValid() {
+				return nil, fmt.Errorf("no field %q", part)
+			}
+			v = f.Interface()
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= rv.Len() {
+				return nil, fmt.Errorf("bad index %q of %d items", part, rv.Len())
+			}
+			v = rv.Index(i).Interface()
+		default:
+			return nil, fmt.Errorf("no field %q in %v", part, v)
+		}
+	}
+
+	return v, nil
+}
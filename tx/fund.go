@@ -0,0 +1,149 @@
+package tx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// eth and credit balances of an account
+type Balance struct {
+	Addr   common.Address `json:"address"`
+	Eth    *big.Int       `json:"eth"`
+	Credit *big.Int       `json:"credit"`
+}
+
+// read the eth and credit balance of addr
+func (tx *Tx) Balance(addr common.Address) (*Balance, error) {
+	eth, err := tx.c.BalanceAt(context.Background(), addr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	credit, err := tx.CreditBalance(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Balance{Addr: addr, Eth: eth, Credit: credit}, nil
+}
+
+// read the credit balance of addr
+func (tx *Tx) CreditBalance(addr common.Address) (*big.Int, error) {
+	out, err := tx.CallView(CreditABI, common.HexToAddress(Contracts.Credit), "balanceOf", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return abi.ConvertType(out[0], new(big.Int)).(*big.Int), nil
+}
+
+// send wei of eth from the owner of sk to addr and wait for it
+func (tx *Tx) SendEth(sk string, to common.Address, wei *big.Int) error {
+	SignedTx, err := MakeSignedTx(tx.c, sk, to, wei, 21000, nil)
+	if err != nil {
+		return err
+	}
+	tx.SignedTx = SignedTx
+
+	return tx.Send()
+}
+
+// send credit from the owner of sk to addr, minting it when the sender has
+// too little and the credit abi has mint(address,uint256)
+func (tx *Tx) SendCredit(sk string, to common.Address, amount *big.Int) error {
+	from, err := KeyAddress(sk)
+	if err != nil {
+		return err
+	}
+
+	have, err := tx.CreditBalance(from)
+	if err != nil {
+		return err
+	}
+
+	method := "transfer"
+	if have.Cmp(amount) < 0 {
+		parsed, err := abi.JSON(strings.NewReader(CreditABI))
+		if err != nil {
+			return err
+		}
+		m, ok := parsed.Methods["mint"]
+		if !ok || len(m.Inputs) != 2 || m.Inputs[0].Type.T != abi.AddressTy {
+			return fmt.Errorf("%s has only %s and credit cannot mint", from, Credit.Format(have))
+		}
+		method = "mint"
+	}
+
+	log.Printf("%s %s to %s", method, Credit.Format(amount), to)
+	data, err := PackData(CreditABI, method, to, amount)
+	if err != nil {
+		return err
+	}
+	if err := tx.MakeContractTx(sk, common.HexToAddress(Contracts.Credit), data); err != nil {
+		return err
+	}
+
+	return tx.Send()
+}
+
+// top up each account to the target eth and credit balance from the owner of sk,
+// a nil target is skipped. returns the balances before and after.
+func (tx *Tx) Fund(sk string, accounts []common.Address, eth, credit *big.Int) ([]*Balance, []*Balance, error) {
+	var before, after []*Balance
+
+	for _, addr := range accounts {
+		b, err := tx.Balance(addr)
+		if err != nil {
+			return before, after, err
+		}
+		before = append(before, b)
+
+		// only the missing part
+		if eth != nil && b.Eth.Cmp(eth) < 0 {
+			diff := new(big.Int).Sub(eth, b.Eth)
+			log.Printf("sending %s ETH to %s", FormatCredit(diff, 18), addr)
+			if err := tx.SendEth(sk, addr, diff); err != nil {
+				return before, after, err
+			}
+		}
+		if credit != nil && b.Credit.Cmp(credit) < 0 {
+			if err := tx.SendCredit(sk, addr, new(big.Int).Sub(credit, b.Credit)); err != nil {
+				return before, after, err
+			}
+		}
+
+		b, err = tx.Balance(addr)
+		if err != nil {
+			return before, after, err
+		}
+		after = append(after, b)
+	}
+
+	return before, after, nil
+}
+
+// balances before and after as a table
+func BalanceTable(before, after []*Balance) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "account\tETH before\tETH after\t"+Credit.Symbol+" before\t"+Credit.Symbol+" after")
+	for i, b := range before {
+		a := b
+		if i < len(after) {
+			a = after[i]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", b.Addr,
+			FormatCredit(b.Eth, 18), FormatCredit(a.Eth, 18),
+			FormatCredit(b.Credit, Credit.Decimals), FormatCredit(a.Credit, Credit.Decimals))
+	}
+	w.Flush()
+
+	return sb.String()
+}
@@ -0,0 +1,241 @@
+// gen writes typed tx builders for every non-view method of the grid contracts.
+//
+//	go run ./gen         write builders_gen.go from the abis
+//	go run ./gen -check  fail when builders_gen.go does not match the abis
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// a contract to generate builders for
+type contract struct {
+	// prefix of generated names, e.g. Registry
+	Name string
+	// abi json file
+	Path string
+	// go var holding the abi json, and the field of tx.Contracts
+	ABIVar string
+	Field  string
+	// go package with the struct types of its tuples, empty if none
+	Pkg string
+}
+
+func main() {
+	out := flag.String("out", "builders_gen.go", "generated file")
+	check := flag.Bool("check", false, "only check that the generated file is up to date")
+	reg := flag.String("registry", "../../grid-contracts/abi/registry/Registry.abi", "registry abi file")
+	mar := flag.String("market", "../../grid-contracts/abi/market/Market.abi", "market abi file")
+	cre := flag.String("credit", "../../grid-contracts/abi/credit/Credit.abi", "credit abi file")
+	flag.Parse()
+
+	src, err := generate(contracts(*reg, *mar, *cre))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *check {
+		old, err := os.ReadFile(*out)
+		if err != nil {
+			log.Fatalf("%v, run go generate ./tx", err)
+		}
+		if !bytes.Equal(old, src) {
+			log.Fatalf("%s does not match the abis, run go generate ./tx", *out)
+		}
+		return
+	}
+
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// the contracts with their abi files
+func contracts(reg, mar, cre string) []contract {
+	return []contract{
+		{Name: "Registry", Path: reg, ABIVar: "RegABI", Field: "Registry", Pkg: "registry"},
+		{Name: "Market", Path: mar, ABIVar: "MarketABI", Field: "Market", Pkg: "market"},
+		{Name: "Credit", Path: cre, ABIVar: "CreditABI", Field: "Credit"},
+	}
+}
+
+// generated source of all builders
+func generate(cs []contract) ([]byte, error) {
+	g := &generator{imports: map[string]bool{
+		"github.com/ethereum/go-ethereum/common": true,
+	}}
+
+	for _, c := range cs {
+		f, err := os.Open(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := abi.JSON(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Path, err)
+		}
+
+		// methods in a stable order
+		names := make([]string, 0, len(parsed.Methods))
+		for name := range parsed.Methods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			m := parsed.Methods[name]
+			if m.IsConstant() {
+				continue
+			}
+			if err := g.method(c, m); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", c.Name, name, err)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go run ./gen; DO NOT EDIT.\n\npackage tx\n\nimport (\n")
+	// std imports first, then the rest
+	var std, ext []string
+	for imp := range g.imports {
+		if strings.Contains(strings.Split(imp, "/")[0], ".") {
+			ext = append(ext, imp)
+		} else {
+			std = append(std, imp)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(ext)
+	for _, imp := range std {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	if len(std) > 0 {
+		b.WriteString("\n")
+	}
+	for _, imp := range ext {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	b.WriteString(")\n")
+	b.Write(g.body.Bytes())
+
+	return format.Source(b.Bytes())
+}
+
+type generator struct {
+	imports map[string]bool
+	body    bytes.Buffer
+}
+
+// write the data builder and Make wrapper of a method
+func (g *generator) method(c contract, m abi.Method) error {
+	fn := c.Name + abi.ToCamelCase(m.Name)
+
+	var params, names []string
+	for i, arg := range m.Inputs {
+		typ, err := g.goType(c, arg.Type)
+		if err != nil {
+			return fmt.Errorf("arg %d: %w", i, err)
+		}
+		name := paramName(arg.Name, i)
+		params = append(params, name+" "+typ)
+		names = append(names, name)
+	}
+	ps, ns := strings.Join(params, ", "), strings.Join(names, ", ")
+
+	call := ""
+	if ns != "" {
+		call = ", " + ns
+	}
+
+	w := &g.body
+	fmt.Fprintf(w, "\n// %sData packs the data for calling %s.%s\n", fn, strings.ToLower(c.Name), m.Name)
+	fmt.Fprintf(w, "func %sData(%s) ([]byte, error) {\n", fn, ps)
+	fmt.Fprintf(w, "\treturn PackData(%s, %q%s)\n}\n", c.ABIVar, m.Name, call)
+
+	if ps != "" {
+		ps = ", " + ps
+	}
+	fmt.Fprintf(w, "\n// Make%sTx makes a signed tx calling %s.%s, sent from the owner of sk\n", fn, strings.ToLower(c.Name), m.Name)
+	fmt.Fprintf(w, "func (tx *Tx) Make%sTx(sk string%s) error {\n", fn, ps)
+	fmt.Fprintf(w, "\tdata, err := %sData(%s)\n\tif err != nil {\n\t\treturn err\n\t}\n\n", fn, ns)
+	fmt.Fprintf(w, "\treturn tx.MakeContractTx(sk, common.HexToAddress(Contracts.%s), data)\n}\n", c.Field)
+
+	return nil
+}
+
+// go type abi packs for t
+func (g *generator) goType(c contract, t abi.Type) (string, error) {
+	switch t.T {
+	case abi.AddressTy:
+		return "common.Address", nil
+	case abi.BoolTy:
+		return "bool", nil
+	case abi.StringTy:
+		return "string", nil
+	case abi.BytesTy:
+		return "[]byte", nil
+	case abi.FixedBytesTy:
+		return fmt.Sprintf("[%d]byte", t.Size), nil
+	case abi.IntTy, abi.UintTy:
+		switch t.Size {
+		case 8, 16, 32, 64:
+			if t.T == abi.IntTy {
+				return fmt.Sprintf("int%d", t.Size), nil
+			}
+			return fmt.Sprintf("uint%d", t.Size), nil
+		}
+		g.imports["math/big"] = true
+		return "*big.Int", nil
+	case abi.SliceTy, abi.ArrayTy:
+		elem, err := g.goType(c, *t.Elem)
+		if err != nil {
+			return "", err
+		}
+		if t.T == abi.ArrayTy {
+			return fmt.Sprintf("[%d]%s", t.Size, elem), nil
+		}
+		return "[]" + elem, nil
+	case abi.TupleTy:
+		// the struct types abigen made for this contract
+		if c.Pkg == "" || t.TupleRawName == "" {
+			return "", fmt.Errorf("no go struct for tuple %s", t)
+		}
+		g.imports["github.com/grid/contracts/go/"+c.Pkg] = true
+		return c.Pkg + "." + t.TupleRawName, nil
+	}
+
+	return "", fmt.Errorf("unsupported type %s", t)
+}
+
+// names used in the generated bodies and imports
+var reserved = map[string]bool{
+	"tx": true, "sk": true, "data": true, "err": true,
+	"common": true, "big": true, "registry": true, "market": true,
+}
+
+// a go param name for an abi arg name
+func paramName(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("arg%d", i)
+	}
+
+	name = abi.ToCamelCase(name)
+	name = strings.ToLower(name[:1]) + name[1:]
+
+	if token.IsKeyword(name) || reserved[name] {
+		return name + "_"
+	}
+
+	return name
+}
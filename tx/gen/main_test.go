@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// the committed builders must match the abis of the grid-contracts checkout next to this module,
+// the one go.mod replaces github.com/grid/contracts with, as go run ./gen -check does
+func TestBuildersUpToDate(t *testing.T) {
+	src, err := generate(contracts(
+		"../../../grid-contracts/abi/registry/Registry.abi",
+		"../../../grid-contracts/abi/market/Market.abi",
+		"../../../grid-contracts/abi/credit/Credit.abi",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := os.ReadFile("../builders_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(old, src) {
+		t.Fatal("builders_gen.go does not match the abis, run go generate ./tx")
+	}
+}
@@ -0,0 +1,530 @@
+package tx
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	bolt "go.etcd.io/bbolt"
+)
+
+// hashes of the last indexed ranges kept to find where a reorg forked
+var KeepHashes = 64
+
+// buckets of the index
+var (
+	// the checkpoint of the indexed chain, contracts and height
+	metaBucket = []byte("meta")
+	// height of an indexed range's last block to its hash
+	hashesBucket = []byte("hashes")
+	// block and log index to the decoded event
+	eventsBucket = []byte("events")
+	// an address in an event, its block and log index to nothing
+	accountsBucket = []byte("accounts")
+)
+
+var checkpointKey = []byte("checkpoint")
+
+// a local store of the contracts' events, answering queries without reading the chain
+type Index struct {
+	db *bolt.DB
+}
+
+// open or create the index at path
+func OpenIndex(path string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open index %s: %w", path, err)
+	}
+
+	err = db.Update(func(btx *bolt.Tx) error {
+		for _, b := range [][]byte{metaBucket, hashesBucket, eventsBucket, accountsBucket} {
+			if _, err := btx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Index{db}, nil
+}
+
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// the checkpoint of the index, nil when nothing is indexed yet
+func (ix *Index) Checkpoint() (*Checkpoint, error) {
+	var cp *Checkpoint
+	err := ix.db.View(func(btx *bolt.Tx) error {
+		b := btx.Bucket(metaBucket).Get(checkpointKey)
+		if b == nil {
+			return nil
+		}
+		cp = &Checkpoint{}
+		return json.Unmarshal(b, cp)
+	})
+
+	return cp, err
+}
+
+func blockKey(block uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, block)
+}
+
+func eventKey(block uint64, index uint) []byte {
+	return binary.BigEndian.AppendUint32(blockKey(block), uint32(index))
+}
+
+// the addresses named by the fields of an event, decoded or read back from the index
+func eventAccounts(e *Event) []common.Address {
+	var addrs []common.Address
+	for _, v := range e.Fields {
+		switch a := v.(type) {
+		case common.Address:
+			addrs = append(addrs, a)
+		case string:
+			if len(a) == 42 && common.IsHexAddress(a) {
+				addrs = append(addrs, common.HexToAddress(a))
+			}
+		}
+	}
+
+	return addrs
+}
+
+// store the events of blocks up to height, whose block has hash
+func (ix *Index) write(events []*Event, cp *Checkpoint, hash common.Hash) error {
+	return ix.db.Update(func(btx *bolt.Tx) error {
+		evb, accb := btx.Bucket(eventsBucket), btx.Bucket(accountsBucket)
+		for _, e := range events {
+			key := eventKey(e.Block, e.Index)
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if err := evb.Put(key, b); err != nil {
+				return err
+			}
+			for _, a := range eventAccounts(e) {
+				if err := accb.Put(append(a.Bytes(), key...), nil); err != nil {
+					return err
+				}
+			}
+		}
+
+		hb := btx.Bucket(hashesBucket)
+		if err := hb.Put(blockKey(cp.Block), hash.Bytes()); err != nil {
+			return err
+		}
+		// forget the oldest hashes, counted with a cursor as the stats miss the writes of this tx
+		var kept [][]byte
+		hc := hb.Cursor()
+		for k, _ := hc.First(); k != nil; k, _ = hc.Next() {
+			kept = append(kept, append([]byte{}, k...))
+		}
+		for _, k := range kept[:max(len(kept)-KeepHashes, 0)] {
+			if err := hb.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		b, err := json.Marshal(cp)
+		if err != nil {
+			return err
+		}
+		return btx.Bucket(metaBucket).Put(checkpointKey, b)
+	})
+}
+
+// drop everything above height, after a reorg
+func (ix *Index) rollback(height uint64) error {
+	return ix.db.Update(func(btx *bolt.Tx) error {
+		evb, accb := btx.Bucket(eventsBucket), btx.Bucket(accountsBucket)
+		var drop [][]byte
+		c := evb.Cursor()
+		for k, v := c.Seek(blockKey(height + 1)); k != nil; k, v = c.Next() {
+			e, err := readEvent(v)
+			if err != nil {
+				return err
+			}
+			for _, a := range eventAccounts(e) {
+				if err := accb.Delete(append(a.Bytes(), k...)); err != nil {
+					return err
+				}
+			}
+			drop = append(drop, append([]byte{}, k...))
+		}
+		for _, k := range drop {
+			if err := evb.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		hb := btx.Bucket(hashesBucket)
+		drop = nil
+		hc := hb.Cursor()
+		for k, _ := hc.Seek(blockKey(height + 1)); k != nil; k, _ = hc.Next() {
+			drop = append(drop, append([]byte{}, k...))
+		}
+		for _, k := range drop {
+			if err := hb.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		meta := btx.Bucket(metaBucket)
+		cp := &Checkpoint{}
+		if err := json.Unmarshal(meta.Get(checkpointKey), cp); err != nil {
+			return err
+		}
+		cp.Block = height
+		b, err := json.Marshal(cp)
+		if err != nil {
+			return err
+		}
+		return meta.Put(checkpointKey, b)
+	})
+}
+
+// the hashes kept at and below height, highest first
+func (ix *Index) hashes(height uint64) (heights []uint64, hashes []common.Hash, err error) {
+	err = ix.db.View(func(btx *bolt.Tx) error {
+		c := btx.Bucket(hashesBucket).Cursor()
+		k, v := c.Seek(blockKey(height + 1))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil; k, v = c.Prev() {
+			heights = append(heights, binary.BigEndian.Uint64(k))
+			hashes = append(hashes, common.BytesToHash(v))
+		}
+		return nil
+	})
+
+	return heights, hashes, err
+}
+
+// an event read back from the index, numbers kept exact
+func readEvent(b []byte) (*Event, error) {
+	e := &Event{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// index the contracts' events from block from, or from the index's height,
+// up to the head minus confirmations. a reorg of indexed blocks is rolled back first
+func (tx *Tx) SyncIndex(ix *Index, chain string, from, confirmations uint64) (*Checkpoint, int, error) {
+	want := NewCheckpoint(chain, 0)
+	cp, err := ix.Checkpoint()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start := from
+	if cp != nil {
+		if !cp.Matches(want) {
+			return nil, 0, fmt.Errorf("the index is of chain %s with contracts %v, not of these, give another -db or -reset", cp.Chain, cp.Contracts)
+		}
+		safe, err := tx.forkPoint(ix, cp.Block)
+		if err != nil {
+			return nil, 0, err
+		}
+		if safe != cp.Block {
+			log.Printf("index: reorg, rolling back from block %d to %d", cp.Block, safe)
+			if err := ix.rollback(safe); err != nil {
+				return nil, 0, err
+			}
+		}
+		cp.Block = safe
+		start = safe + 1
+	}
+
+	head, err := tx.HeadBlock()
+	if err != nil {
+		return cp, 0, err
+	}
+	if head < confirmations || head-confirmations < start {
+		return cp, 0, nil
+	}
+	safeHead := head - confirmations
+
+	n := 0
+	for start <= safeHead {
+		end := start + LogsRange - 1
+		if end > safeHead {
+			end = safeHead
+		}
+
+		logs, err := tx.ContractLogs(start, end)
+		if err != nil {
+			return cp, n, err
+		}
+		var events []*Event
+		for i := range logs {
+			ev, err := DecodeLog(&logs[i])
+			if err != nil {
+				log.Printf("index: block %d: %v", logs[i].BlockNumber, err)
+				continue
+			}
+			events = append(events, ev)
+		}
+
+		header, err := tx.c.HeaderByNumber(context.Background(), new(big.Int).SetUint64(end))
+		if err != nil {
+			return cp, n, err
+		}
+		cp = NewCheckpoint(chain, end)
+		if err := ix.write(events, cp, header.Hash()); err != nil {
+			return cp, n, err
+		}
+		n += len(events)
+		log.Printf("index: blocks %d to %d, %d events", start, end, len(events))
+
+		start = end + 1
+	}
+
+	return cp, n, nil
+}
+
+// the highest kept height at or below height still on the chain
+func (tx *Tx) forkPoint(ix *Index, height uint64) (uint64, error) {
+	heights, hashes, err := ix.hashes(height)
+	if err != nil {
+		return 0, err
+	}
+	if len(heights) == 0 {
+		return height, nil
+	}
+
+	for i, h := range heights {
+		header, err := tx.c.HeaderByNumber(context.Background(), new(big.Int).SetUint64(h))
+		if err != nil {
+			return 0, err
+		}
+		if header.Hash() == hashes[i] {
+			return h, nil
+		}
+	}
+
+	return 0, fmt.Errorf("reorg below block %d, the oldest kept hash, rebuild the index with -reset", heights[len(heights)-1])
+}
+
+// call fn with the indexed events in chain order
+func (ix *Index) Events(fn func(e *Event) error) error {
+	return ix.db.View(func(btx *bolt.Tx) error {
+		return btx.Bucket(eventsBucket).ForEach(func(k, v []byte) error {
+			e, err := readEvent(v)
+			if err != nil {
+				return err
+			}
+			return fn(e)
+		})
+	})
+}
+
+// the indexed events naming the account, in chain order
+func (ix *Index) AccountEvents(addr common.Address) ([]*Event, error) {
+	var events []*Event
+	err := ix.db.View(func(btx *bolt.Tx) error {
+		evb := btx.Bucket(eventsBucket)
+		c := btx.Bucket(accountsBucket).Cursor()
+		prefix := addr.Bytes()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			e, err := readEvent(evb.Get(k[len(prefix):]))
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+
+	return events, err
+}
+
+// a cp as its registry events show it
+type CPInfo struct {
+	Address    common.Address `json:"address"`
+	Name       string         `json:"name,omitempty"`
+	FirstBlock uint64         `json:"firstBlock"`
+	LastBlock  uint64         `json:"lastBlock"`
+	LastEvent  string         `json:"lastEvent"`
+	Events     int            `json:"events"`
+}
+
+// a node of a cp as its registry events show it
+type NodeInfo struct {
+	CP         common.Address         `json:"cp"`
+	ID         string                 `json:"id"`
+	FirstBlock uint64                 `json:"firstBlock"`
+	LastBlock  uint64                 `json:"lastBlock"`
+	LastEvent  string                 `json:"lastEvent"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+// an order between a user and a provider, its status is the name of its last market event
+type OrderInfo struct {
+	User       common.Address `json:"user"`
+	Provider   common.Address `json:"provider"`
+	NodeID     string         `json:"nodeId,omitempty"`
+	Status     string         `json:"status"`
+	FirstBlock uint64         `json:"firstBlock"`
+	LastBlock  uint64         `json:"lastBlock"`
+	Events     int            `json:"events"`
+}
+
+// the cps named by registry events
+func (ix *Index) CPs() ([]*CPInfo, error) {
+	cps := map[common.Address]*CPInfo{}
+	err := ix.Events(func(e *Event) error {
+		if e.Contract != "registry" {
+			return nil
+		}
+		v, ok := eventField(e, providerFields)
+		if !ok || !common.IsHexAddress(v) {
+			return nil
+		}
+		addr := common.HexToAddress(v)
+		cp, ok := cps[addr]
+		if !ok {
+			cp = &CPInfo{Address: addr, FirstBlock: e.Block}
+			cps[addr] = cp
+		}
+		if name, ok := eventField(e, []string{"name"}); ok {
+			cp.Name = name
+		}
+		cp.LastBlock, cp.LastEvent = e.Block, e.Name
+		cp.Events++
+		return nil
+	})
+
+	list := make([]*CPInfo, 0, len(cps))
+	for _, cp := range cps {
+		list = append(list, cp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].FirstBlock < list[j].FirstBlock })
+
+	return list, err
+}
+
+// the nodes of a cp named by registry events
+func (ix *Index) Nodes(cp common.Address) ([]*NodeInfo, error) {
+	events, err := ix.AccountEvents(cp)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[string]*NodeInfo{}
+	var ids []string
+	for _, e := range events {
+		if e.Contract != "registry" || !hasField(e, providerFields, cp.Hex()) {
+			continue
+		}
+		id, ok := eventField(e, eventNodeFields(e))
+		if !ok {
+			continue
+		}
+		n, ok := nodes[id]
+		if !ok {
+			n = &NodeInfo{CP: cp, ID: id, FirstBlock: e.Block}
+			nodes[id] = n
+			ids = append(ids, id)
+		}
+		n.LastBlock, n.LastEvent, n.Fields = e.Block, e.Name, e.Fields
+	}
+
+	list := make([]*NodeInfo, len(ids))
+	for i, id := range ids {
+		list[i] = nodes[id]
+	}
+
+	return list, nil
+}
+
+// the orders named by market events, filtered by user, provider and a status substring
+func (ix *Index) Orders(user, provider *common.Address, status string) ([]*OrderInfo, error) {
+	orders := map[[2]common.Address]*OrderInfo{}
+	var keys [][2]common.Address
+	err := ix.Events(func(e *Event) error {
+		if e.Contract != "market" {
+			return nil
+		}
+		u, uok := eventField(e, userFields)
+		p, pok := eventField(e, providerFields)
+		if !uok || !pok || !common.IsHexAddress(u) || !common.IsHexAddress(p) {
+			return nil
+		}
+		key := [2]common.Address{common.HexToAddress(u), common.HexToAddress(p)}
+		o, ok := orders[key]
+		if !ok {
+			o = &OrderInfo{User: key[0], Provider: key[1], FirstBlock: e.Block}
+			orders[key] = o
+			keys = append(keys, key)
+		}
+		if id, ok := eventField(e, eventNodeFields(e)); ok {
+			o.NodeID = id
+		}
+		o.Status, o.LastBlock = e.Name, e.Block
+		o.Events++
+		return nil
+	})
+
+	var list []*OrderInfo
+	for _, key := range keys {
+		o := orders[key]
+		if user != nil && o.User != *user {
+			continue
+		}
+		if provider != nil && o.Provider != *provider {
+			continue
+		}
+		if status != "" && !strings.Contains(strings.ToLower(o.Status), strings.ToLower(status)) {
+			continue
+		}
+		list = append(list, o)
+	}
+
+	return list, err
+}
+
+// the credit Transfer events from or to the account
+func (ix *Index) Transfers(addr common.Address) ([]*Event, error) {
+	events, err := ix.AccountEvents(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []*Event
+	for _, e := range events {
+		if e.Contract == "credit" && e.Name == "Transfer" {
+			transfers = append(transfers, e)
+		}
+	}
+
+	return transfers, nil
+}
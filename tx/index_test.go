@@ -0,0 +1,62 @@
+package tx
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// more ranges than the hashes kept, then a reorg rolled back
+func TestIndexWriteRollback(t *testing.T) {
+	ix, err := OpenIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	ranges := uint64(KeepHashes + 6)
+	// ranges of 10 blocks with an event in their last block
+	for i := uint64(1); i <= ranges; i++ {
+		end := i * 10
+		e := &Event{Contract: "market", Name: "OrderCreated", Block: end, Fields: map[string]interface{}{"user": user}}
+		if err := ix.write([]*Event{e}, NewCheckpoint("local", end), common.BigToHash(new(big.Int).SetUint64(end))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	heights, _, err := ix.hashes(ranges * 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(heights) != KeepHashes || heights[0] != ranges*10 || heights[len(heights)-1] != 70 {
+		t.Fatalf("kept %d hashes from %d down to %d, want %d from %d down to 70", len(heights), heights[0], heights[len(heights)-1], KeepHashes, ranges*10)
+	}
+
+	// a reorg forked after block 500
+	if err := ix.rollback(500); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := ix.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Block != 500 {
+		t.Errorf("checkpoint at block %d, want 500", cp.Block)
+	}
+	if heights, _, err = ix.hashes(ranges * 10); err != nil {
+		t.Fatal(err)
+	}
+	if heights[0] != 500 || len(heights) != 44 {
+		t.Errorf("kept %d hashes from %d, want 44 from 500", len(heights), heights[0])
+	}
+	events, err := ix.AccountEvents(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 50 || events[len(events)-1].Block != 500 {
+		t.Errorf("%d events of the user up to block %d, want 50 up to 500", len(events), events[len(events)-1].Block)
+	}
+}
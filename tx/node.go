@@ -0,0 +1,65 @@
+package tx
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/go/registry"
+)
+
+// node params given by the provider, prices are monthly credit amounts
+type NodeSpec struct {
+	CpuModel  string
+	CpuPrice  string
+	GpuModel  string
+	GpuPrice  string
+	Mem       uint64
+	MemPrice  string
+	Disk      uint64
+	DiskPrice string
+}
+
+// check the spec and turn it into a node of the configured provider
+func (spec *NodeSpec) Node() (*registry.IRegistryNode, error) {
+	prices := make([]*big.Int, 4)
+	for i, s := range []string{spec.CpuPrice, spec.GpuPrice, spec.MemPrice, spec.DiskPrice} {
+		v, err := Credit.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("price %q: %w", s, err)
+		}
+		prices[i] = v
+	}
+
+	if spec.CpuModel == "" {
+		return nil, fmt.Errorf("cpu model is required")
+	}
+
+	// the registry derives the price per second from the monthly one
+	info := registry.IRegistryNode{
+		Cp: common.HexToAddress(P_ADDR),
+
+		Cpu: registry.IRegistryCPU{
+			PriceMon: prices[0],
+			PriceSec: new(big.Int),
+			Model:    spec.CpuModel,
+		},
+		Gpu: registry.IRegistryGPU{
+			PriceMon: prices[1],
+			PriceSec: new(big.Int),
+			Model:    spec.GpuModel,
+		},
+		Mem: registry.IRegistryMEM{
+			Num:      spec.Mem,
+			PriceMon: prices[2],
+			PriceSec: new(big.Int),
+		},
+		Disk: registry.IRegistryDISK{
+			Num:      spec.Disk,
+			PriceMon: prices[3],
+			PriceSec: new(big.Int),
+		},
+	}
+
+	return &info, nil
+}
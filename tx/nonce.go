@@ -0,0 +1,58 @@
+package tx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// hands out nonces per sender, ahead of the chain's pending nonce
+// while txs signed in this process are not in the pool yet
+type NonceManager struct {
+	mu   sync.Mutex
+	next map[common.Address]uint64
+}
+
+// the nonces of every tx signed here
+var Nonces = &NonceManager{next: map[common.Address]uint64{}}
+
+// reserve the nonces of addr up to the one of a submitted tx
+func (n *NonceManager) Sent(addr common.Address, nonce uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if next, ok := n.next[addr]; !ok || nonce >= next {
+		n.next[addr] = nonce + 1
+	}
+}
+
+// the next nonce of addr, not reserved until a tx with it is submitted
+func (n *NonceManager) Peek(client *ethclient.Client, addr common.Address) (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.peek(client, addr)
+}
+
+func (n *NonceManager) peek(client *ethclient.Client, addr common.Address) (uint64, error) {
+	nonce, err := client.PendingNonceAt(context.Background(), addr)
+	if err != nil {
+		return 0, err
+	}
+	if next, ok := n.next[addr]; ok && next > nonce {
+		nonce = next
+	}
+
+	return nonce, nil
+}
+
+// forget the nonces reserved for addr after a tx of it was not sent,
+// the chain's pending nonce is used again
+func (n *NonceManager) Forget(addr common.Address) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.next, addr)
+}
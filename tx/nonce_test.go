@@ -0,0 +1,53 @@
+package tx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/rockiecn/sendtx/internal/rpctest"
+)
+
+func TestNonceManager(t *testing.T) {
+	pending := "0x5"
+	txObj := NewTx(rpctest.Serve(t, rpctest.Handlers{
+		"eth_getTransactionCount": func(json.RawMessage) (interface{}, error) { return pending, nil },
+	}))
+	n := &NonceManager{next: map[common.Address]uint64{}}
+	a, b := common.HexToAddress("0xa1"), common.HexToAddress("0xb1")
+
+	check := func(what string, got uint64, err error, want uint64) {
+		t.Helper()
+		if err != nil || got != want {
+			t.Errorf("%s = %d, %v, want %d", what, got, err, want)
+		}
+	}
+
+	got, err := n.Peek(txObj.c, a)
+	check("peek", got, err, 5)
+	got, err = n.Peek(txObj.c, a)
+	check("peek again, nothing sent", got, err, 5)
+
+	n.Sent(a, 5)
+	got, err = n.Peek(txObj.c, a)
+	check("peek while the sent 5 is not pending", got, err, 6)
+	n.Sent(a, 6)
+	n.Sent(a, 4)
+	got, err = n.Peek(txObj.c, a)
+	check("peek after an older nonce is sent again", got, err, 7)
+	got, err = n.Peek(txObj.c, b)
+	check("peek of another sender", got, err, 5)
+	n.Sent(b, 5)
+
+	// the pool caught up past the reserved nonces
+	pending = "0x9"
+	got, err = n.Peek(txObj.c, a)
+	check("peek behind the chain", got, err, 9)
+
+	n.Forget(a)
+	pending = "0x6"
+	got, err = n.Peek(txObj.c, a)
+	check("peek after forget", got, err, 6)
+	got, err = n.Peek(txObj.c, b)
+	check("peek of the sender not forgotten", got, err, 6)
+}
@@ -0,0 +1,223 @@
+package tx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/go/market"
+)
+
+// order params given by the user, all in human units
+type OrderSpec struct {
+	// provider address, or an alias: provider, user, admin
+	Provider string
+	// the cp's node selected by this order
+	NodeId uint64
+	// deposit in credit units, e.g. 40.5, 40.5 CRD or wei:40500000
+	Deposit string
+	// durations like 30s, 2h, 30d
+	Probation string
+	Duration  string
+}
+
+// read an order spec file, json like order.example.json, the fields it lacks keep their value
+func (spec *OrderSpec) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var f struct {
+		Provider  *string `json:"provider"`
+		NodeId    *uint64 `json:"nodeId"`
+		Deposit   *string `json:"deposit"`
+		Probation *string `json:"probation"`
+		Duration  *string `json:"duration"`
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if f.Provider != nil {
+		spec.Provider = *f.Provider
+	}
+	if f.NodeId != nil {
+		spec.NodeId = *f.NodeId
+	}
+	if f.Deposit != nil {
+		spec.Deposit = *f.Deposit
+	}
+	if f.Probation != nil {
+		spec.Probation = *f.Probation
+	}
+	if f.Duration != nil {
+		spec.Duration = *f.Duration
+	}
+
+	return nil
+}
+
+// resolve an address, a role alias or an account name into an address
+func ResolveAddress(s string) (common.Address, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "provider":
+		return common.HexToAddress(P_ADDR), nil
+	case "user":
+		return common.HexToAddress(U_ADDR), nil
+	case "admin":
+		return common.HexToAddress(A_ADDR), nil
+	}
+
+	if a, ok := Accounts[strings.TrimSpace(s)]; ok {
+		return a.Addr()
+	}
+
+	if !common.IsHexAddress(s) {
+		return common.Address{}, fmt.Errorf("invalid address or alias: %q", s)
+	}
+
+	return common.HexToAddress(s), nil
+}
+
+// parse a human duration into seconds, accepts time.ParseDuration units plus d for days
+func ParseDuration(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	// days are not supported by time.ParseDuration
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.ParseUint(strings.TrimSuffix(s, "d"), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		if n > math.MaxUint64/(24*3600) {
+			return 0, fmt.Errorf("duration %q is too long", s)
+		}
+		return n * 24 * 3600, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+	if d%time.Second != 0 {
+		return 0, fmt.Errorf("duration %q is not a whole number of seconds", s)
+	}
+
+	return uint64(d / time.Second), nil
+}
+
+// parse a decimal credit amount into base units with the given decimals
+func ParseCredit(s string, decimals uint8) (*big.Int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty amount")
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+
+	// pad the fraction to full precision then parse as one integer
+	frac += strings.Repeat("0", int(decimals)-len(frac))
+	v, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	if v.Sign() < 0 {
+		return nil, fmt.Errorf("negative amount %q", s)
+	}
+
+	return v, nil
+}
+
+// format base units as a decimal credit amount
+func FormatCredit(v *big.Int, decimals uint8) string {
+	if v == nil {
+		return "0"
+	}
+
+	s := new(big.Int).Abs(v).String()
+	if len(s) <= int(decimals) {
+		s = strings.Repeat("0", int(decimals)-len(s)+1) + s
+	}
+
+	whole, frac := s[:len(s)-int(decimals)], strings.TrimRight(s[len(s)-int(decimals):], "0")
+	if v.Sign() < 0 {
+		whole = "-" + whole
+	}
+	if frac == "" {
+		return whole
+	}
+
+	return whole + "." + frac
+}
+
+// check the spec and turn it into an order for market.createOrder
+func (spec *OrderSpec) Order() (*market.IMarketOrder, error) {
+	provider, err := ResolveAddress(spec.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("provider: %w", err)
+	}
+	if provider == (common.Address{}) {
+		return nil, fmt.Errorf("provider: zero address")
+	}
+
+	if spec.Deposit == "" {
+		return nil, fmt.Errorf("deposit is required")
+	}
+	deposit, err := Credit.Parse(spec.Deposit)
+	if err != nil {
+		return nil, fmt.Errorf("deposit: %w", err)
+	}
+	if deposit.Sign() == 0 {
+		return nil, fmt.Errorf("deposit must be greater than 0")
+	}
+
+	probation, err := ParseDuration(spec.Probation)
+	if err != nil {
+		return nil, fmt.Errorf("probation: %w", err)
+	}
+	duration, err := ParseDuration(spec.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("duration: %w", err)
+	}
+	if duration == 0 {
+		return nil, fmt.Errorf("duration must be greater than 0")
+	}
+	if probation >= duration {
+		return nil, fmt.Errorf("probation (%ds) must be shorter than duration (%ds)", probation, duration)
+	}
+
+	// make an order
+	order := market.IMarketOrder{
+		Provider: provider,
+		NodeId:   spec.NodeId,
+
+		Remain:         deposit,
+		Remuneration:   new(big.Int),
+		ActivateTime:   new(big.Int),
+		LastSettleTime: new(big.Int),
+		Probation:      new(big.Int).SetUint64(probation),
+		Duration:       new(big.Int).SetUint64(duration),
+		Status:         1, // unactive
+	}
+
+	return &order, nil
+}
@@ -0,0 +1,108 @@
+package tx
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+		err  bool
+	}{
+		{"30s", 30, false},
+		{"2h", 7200, false},
+		{" 1h30m ", 5400, false},
+		{"30d", 30 * 24 * 3600, false},
+		{"0d", 0, false},
+		{"213503982334601d", 213503982334601 * 24 * 3600, false},
+		// would wrap around uint64
+		{"213503982334602d", 0, true},
+		{"18446744073709551615d", 0, true},
+		{"-1d", 0, true},
+		{"-2h", 0, true},
+		{"1.5s", 0, true},
+		{"1.5d", 0, true},
+		{"d", 0, true},
+		{"", 0, true},
+		{"ten", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseDuration(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseCredit(t *testing.T) {
+	tests := []struct {
+		in       string
+		decimals uint8
+		want     string
+		err      bool
+	}{
+		{"40", 6, "40000000", false},
+		{"40.5", 6, "40500000", false},
+		{"0.000001", 6, "1", false},
+		{".5", 2, "50", false},
+		{"7", 0, "7", false},
+		{" 1.25 ", 18, "1250000000000000000", false},
+		{"0.0000001", 6, "", true},
+		{"-1", 6, "", true},
+		{"1.2.3", 6, "", true},
+		{"abc", 6, "", true},
+		{"", 6, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCredit(tt.in, tt.decimals)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseCredit(%q, %d) error = %v, want error %v", tt.in, tt.decimals, err, tt.err)
+			continue
+		}
+		if err == nil && got.Cmp(mustBig(t, tt.want)) != 0 {
+			t.Errorf("ParseCredit(%q, %d) = %s, want %s", tt.in, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestOrderSpecLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "order.json")
+	if err := os.WriteFile(path, []byte(`{"provider": "user", "nodeId": 0, "deposit": "40.5"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec := OrderSpec{Provider: "provider", NodeId: 1, Duration: "30d", Probation: "5s"}
+	if err := spec.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	want := OrderSpec{Provider: "user", NodeId: 0, Deposit: "40.5", Duration: "30d", Probation: "5s"}
+	if spec != want {
+		t.Errorf("Load = %+v, want %+v", spec, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"node": 3}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.Load(path); err == nil {
+		t.Error("Load of an unknown field succeeded")
+	}
+}
+
+func mustBig(t *testing.T, s string) *big.Int {
+	t.Helper()
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("bad test number %q", s)
+	}
+	return v
+}
@@ -0,0 +1,275 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/grid/contracts/go/market"
+	"github.com/grid/contracts/go/registry"
+)
+
+// query chain state before signing and refuse txs bound to fail
+var Preflight = true
+
+// an operation refused by a preflight check, with how to fix it
+type PreflightError struct {
+	Op      string
+	Problem string
+	Fix     string
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight %s: %s\n  fix: %s", e.Op, e.Problem, e.Fix)
+}
+
+// the json-rpc error code of a reverted eth_call or gas estimate
+const revertCode = 3
+
+// is err a reverted call rather than a failed rpc: a reverted tx, an rpc error with the revert code,
+// or an rpc error carrying revert data
+func IsRevert(err error) bool {
+	var re *RevertError
+	if errors.As(err, &re) {
+		return true
+	}
+
+	var ce rpc.Error
+	if errors.As(err, &ce) && ce.ErrorCode() == revertCode {
+		return true
+	}
+	var de rpc.DataError
+	if errors.As(err, &de) {
+		s, ok := de.ErrorData().(string)
+		return ok && strings.HasPrefix(s, "0x")
+	}
+
+	return false
+}
+
+// the name of a view the preflight needs, ok is false when the abi lacks it and the check
+// is skipped, in strict mode that fails
+func preflightView(v *View) (name string, ok bool, err error) {
+	name, err = v.Method()
+	if err != nil {
+		return "", false, warn("preflight: %v, skipping the check", err)
+	}
+
+	return name, true, nil
+}
+
+// is the cp registered, ok is false when it cannot be checked
+func (tx *Tx) cpRegistered(addr common.Address) (registered bool, ok bool, err error) {
+	if _, ok, err := preflightView(CPView); !ok {
+		return false, false, err
+	}
+
+	cp, err := tx.GetCP(addr)
+	if IsRevert(err) {
+		return false, true, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	return cp.Addr == addr, true, nil
+}
+
+// does the node exist, ok is false when it cannot be checked
+func (tx *Tx) nodeExists(cp common.Address, id uint64) (exists bool, ok bool, err error) {
+	if _, ok, err := preflightView(NodeView); !ok {
+		return false, false, err
+	}
+
+	node, err := tx.GetNode(cp, id)
+	if IsRevert(err) {
+		return false, true, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	return node.Cp == cp, true, nil
+}
+
+// does the user have an order with provider, ok is false when it cannot be checked
+func (tx *Tx) orderExists(user, provider common.Address) (exists bool, ok bool, err error) {
+	if _, ok, err := preflightView(OrderView); !ok {
+		return false, false, err
+	}
+
+	order, err := tx.GetOrder(user, provider)
+	if IsRevert(err) {
+		return false, true, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	return order.Provider == provider, true, nil
+}
+
+// register fails when the cp is already registered
+func (tx *Tx) PreflightRegister(cp *registry.IRegistryCP) error {
+	if !Preflight {
+		return nil
+	}
+
+	registered, ok, err := tx.cpRegistered(cp.Addr)
+	if err != nil || !ok {
+		return err
+	}
+	if registered {
+		return &PreflightError{
+			Op:      "register",
+			Problem: fmt.Sprintf("cp %s is already registered", cp.Addr),
+			Fix:     "use sendtx revise or sendtx update-cp to change its info",
+		}
+	}
+
+	return nil
+}
+
+// add_node fails when the cp is not registered
+func (tx *Tx) PreflightAddNode(node *registry.IRegistryNode) error {
+	if !Preflight {
+		return nil
+	}
+
+	registered, ok, err := tx.cpRegistered(node.Cp)
+	if err != nil || !ok {
+		return err
+	}
+	if !registered {
+		return &PreflightError{
+			Op:      "add_node",
+			Problem: fmt.Sprintf("cp %s is not registered", node.Cp),
+			Fix:     "register the cp first with sendtx register",
+		}
+	}
+
+	return nil
+}
+
+// createOrder fails without enough allowance or balance, or with an unknown provider or node
+func (tx *Tx) PreflightCreateOrder(user common.Address, order *market.IMarketOrder) error {
+	if !Preflight {
+		return nil
+	}
+
+	const op = "createOrder"
+	credit := common.HexToAddress(Contracts.Credit)
+	marketAddr := common.HexToAddress(Contracts.Market)
+
+	// provider and node
+	registered, ok, err := tx.cpRegistered(order.Provider)
+	if err != nil {
+		return err
+	}
+	if ok && !registered {
+		return &PreflightError{
+			Op:      op,
+			Problem: fmt.Sprintf("provider %s is not a registered cp", order.Provider),
+			Fix:     "check -provider, or register the cp first with sendtx register",
+		}
+	}
+
+	exists, ok, err := tx.nodeExists(order.Provider, order.NodeId)
+	if err != nil {
+		return err
+	}
+	if ok && !exists {
+		return &PreflightError{
+			Op:      op,
+			Problem: fmt.Sprintf("provider %s has no node %d", order.Provider, order.NodeId),
+			Fix:     "check -node, or add the node first with sendtx add-node",
+		}
+	}
+
+	// balance
+	balance, err := tx.CreditBalance(user)
+	if err != nil {
+		return err
+	}
+	if balance.Cmp(order.Remain) < 0 {
+		return &PreflightError{
+			Op:      op,
+			Problem: fmt.Sprintf("user %s has %s, below the deposit %s", user, Credit.Format(balance), Credit.Format(order.Remain)),
+			Fix:     fmt.Sprintf("fund the user: sendtx fund user -credit %s%s, or lower -deposit", WeiPrefix, order.Remain),
+		}
+	}
+
+	// allowance to market
+	out, err := tx.CallView(CreditABI, credit, "allowance", user, marketAddr)
+	if err != nil {
+		return err
+	}
+	allowance := abi.ConvertType(out[0], new(big.Int)).(*big.Int)
+	if allowance.Cmp(order.Remain) < 0 {
+		return &PreflightError{
+			Op:      op,
+			Problem: fmt.Sprintf("user %s allows market only %s, below the deposit %s", user, Credit.Format(allowance), Credit.Format(order.Remain)),
+			Fix:     fmt.Sprintf("approve the deposit to market: sendtx approve -amount %s%s", WeiPrefix, order.Remain),
+		}
+	}
+
+	return nil
+}
+
+// userConfirm and userCancel fail without an order of the user with provider
+func (tx *Tx) PreflightUserOp(op string, user, provider common.Address) error {
+	if !Preflight {
+		return nil
+	}
+
+	exists, ok, err := tx.orderExists(user, provider)
+	if err != nil || !ok {
+		return err
+	}
+	if !exists {
+		return &PreflightError{
+			Op:      op,
+			Problem: fmt.Sprintf("user %s has no order with provider %s", user, provider),
+			Fix:     "check -provider, or create the order first with sendtx create-order",
+		}
+	}
+
+	return nil
+}
+
+// run the preflight of a known method on the args of a generic call
+func (tx *Tx) preflightCall(contract string, m abi.Method, sk string, vals []interface{}) error {
+	if !Preflight {
+		return nil
+	}
+
+	signer, err := KeyAddress(sk)
+	if err != nil {
+		return err
+	}
+
+	switch contract + "." + m.Name {
+	case "registry.register":
+		if cp, err := convertType(vals[0], new(registry.IRegistryCP)); err == nil {
+			return tx.PreflightRegister(cp.(*registry.IRegistryCP))
+		}
+	case "registry.add_node":
+		if node, err := convertType(vals[0], new(registry.IRegistryNode)); err == nil {
+			return tx.PreflightAddNode(node.(*registry.IRegistryNode))
+		}
+	case "market.createOrder":
+		if order, err := convertType(vals[0], new(market.IMarketOrder)); err == nil {
+			return tx.PreflightCreateOrder(signer, order.(*market.IMarketOrder))
+		}
+	case "market.userConfirm", "market.userCancel":
+		if provider, ok := vals[0].(common.Address); ok {
+			return tx.PreflightUserOp(m.Name, signer, provider)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package tx
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/go/registry"
+)
+
+// seconds in a month, PriceMon is the price for this long
+const MonthSeconds = 30 * 24 * 3600
+
+// cost of renting a node for a duration, in credit base units
+type Quote struct {
+	Provider common.Address `json:"provider"`
+	NodeId   uint64         `json:"nodeId"`
+	// seconds
+	Duration uint64 `json:"duration"`
+
+	Cpu   *big.Int `json:"cpu"`
+	Gpu   *big.Int `json:"gpu"`
+	Mem   *big.Int `json:"mem"`
+	Disk  *big.Int `json:"disk"`
+	Total *big.Int `json:"total"`
+}
+
+// call a view method of a contract and return the unpacked outputs
+func (tx *Tx) CallView(abiJSON string, to common.Address, name string, args ...interface{}) ([]interface{}, error) {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := parsed.Pack(name, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := tx.c.CallContract(context.Background(), ethereum.CallMsg{To: &to, Data: input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call %s: %w", name, err)
+	}
+
+	return parsed.Unpack(name, out)
+}
+
+// cost of a resource over d seconds, from the price per second, else pro rata of the monthly price.
+// the monthly price is multiplied before dividing so prices below a wei per second are not lost
+func resourceCost(mon, sec *big.Int, d *big.Int) *big.Int {
+	if sec != nil && sec.Sign() > 0 {
+		return new(big.Int).Mul(sec, d)
+	}
+	if mon == nil {
+		return new(big.Int)
+	}
+
+	cost := new(big.Int).Mul(mon, d)
+	return cost.Div(cost, big.NewInt(MonthSeconds))
+}
+
+// compute the cost of each resource of a node over duration seconds
+func NodeQuote(node *registry.IRegistryNode, duration uint64) *Quote {
+	d := new(big.Int).SetUint64(duration)
+
+	q := &Quote{
+		Provider: node.Cp,
+		NodeId:   node.Id,
+		Duration: duration,
+
+		Cpu:  resourceCost(node.Cpu.PriceMon, node.Cpu.PriceSec, d),
+		Gpu:  resourceCost(node.Gpu.PriceMon, node.Gpu.PriceSec, d),
+		Mem:  resourceCost(node.Mem.PriceMon, node.Mem.PriceSec, d),
+		Disk: resourceCost(node.Disk.PriceMon, node.Disk.PriceSec, d),
+	}
+
+	q.Total = new(big.Int).Add(q.Cpu, q.Gpu)
+	q.Total.Add(q.Total, q.Mem)
+	q.Total.Add(q.Total, q.Disk)
+
+	return q
+}
+
+// quote the node and duration given in an order spec
+func (tx *Tx) QuoteOrder(spec *OrderSpec) (*Quote, error) {
+	provider, err := ResolveAddress(spec.Provider)
+	if err != nil {
+		return nil, fmt.Errorf("provider: %w", err)
+	}
+
+	duration, err := ParseDuration(spec.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("duration: %w", err)
+	}
+
+	node, err := tx.GetNode(provider, spec.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	q := NodeQuote(node, duration)
+	// the node struct may not echo the key it was read with
+	q.Provider = provider
+	q.NodeId = spec.NodeId
+
+	return q, nil
+}
+
+// quote as a printable table
+func (q *Quote) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "provider: %s\nnode:     %d\nduration: %ds\n", q.Provider, q.NodeId, q.Duration)
+	fmt.Fprintf(&b, "cpu:      %s\n", Credit.Format(q.Cpu))
+	fmt.Fprintf(&b, "gpu:      %s\n", Credit.Format(q.Gpu))
+	fmt.Fprintf(&b, "mem:      %s\n", Credit.Format(q.Mem))
+	fmt.Fprintf(&b, "disk:     %s\n", Credit.Format(q.Disk))
+	fmt.Fprintf(&b, "total:    %s", Credit.Format(q.Total))
+
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package tx
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/grid/contracts/go/registry"
+)
+
+func TestNodeQuote(t *testing.T) {
+	node := func(cpuMon, cpuSec, memMon int64) *registry.IRegistryNode {
+		return &registry.IRegistryNode{
+			Id:  1,
+			Cpu: registry.IRegistryCPU{PriceMon: big.NewInt(cpuMon), PriceSec: big.NewInt(cpuSec)},
+			Mem: registry.IRegistryMEM{PriceMon: big.NewInt(memMon)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		node     *registry.IRegistryNode
+		duration uint64
+		cpu, mem int64
+	}{
+		{"a month of the monthly price", node(25920000, 0, 259200000), MonthSeconds, 25920000, 259200000},
+		{"half a month", node(25920000, 0, 259200000), MonthSeconds / 2, 12960000, 129600000},
+		// below a wei per second, dividing first quoted 0
+		{"price below a wei per second", node(1000000, 0, 1), 30 * 24 * 3600 / 2, 500000, 0},
+		{"a day of a small price", node(2592000, 0, 0), 24 * 3600, 86400, 0},
+		{"the price per second wins", node(25920000, 3, 0), 100, 300, 0},
+		{"nothing for no time", node(25920000, 0, 259200000), 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		q := NodeQuote(tt.node, tt.duration)
+		if q.Cpu.Cmp(big.NewInt(tt.cpu)) != 0 || q.Mem.Cmp(big.NewInt(tt.mem)) != 0 {
+			t.Errorf("%s: cpu %s mem %s, want %d and %d", tt.name, q.Cpu, q.Mem, tt.cpu, tt.mem)
+		}
+		// unset prices cost nothing
+		if q.Gpu.Sign() != 0 || q.Disk.Sign() != 0 {
+			t.Errorf("%s: gpu %s disk %s, want 0", tt.name, q.Gpu, q.Disk)
+		}
+		want := new(big.Int).Add(q.Cpu, q.Mem)
+		if q.Total.Cmp(want) != 0 {
+			t.Errorf("%s: total %s, want %s", tt.name, q.Total, want)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
-	"log"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -21,6 +20,20 @@ func MakeSignedTx(client *ethclient.Client,
 	gasLimit uint64, // gas limit of this tx
 	data []byte, // data of this tx
 ) (*types.Transaction, error) {
+	return signTx(client, sk, &to, value, gasLimit, data)
+}
+
+// make and sign a contract creation tx, code with constructor args as the data
+func MakeSignedCreateTx(client *ethclient.Client,
+	sk string, // sk of the deployer
+	gasLimit uint64, // gas limit of this tx
+	code []byte, // bytecode and packed constructor args
+) (*types.Transaction, error) {
+	return signTx(client, sk, nil, nil, gasLimit, code)
+}
+
+// sign a tx to the address, or a contract creation when to is nil
+func signTx(client *ethclient.Client, sk string, to *common.Address, value *big.Int, gasLimit uint64, data []byte) (*types.Transaction, error) {
 	// sk
 	privateKey, err := crypto.HexToECDSA(sk) // 你的以太坊账户私钥
 	if err != nil {
@@ -36,27 +49,41 @@ func MakeSignedTx(client *ethclient.Client,
 	// get the from addr with pk
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 
-	// get the nonce from client
-	nonce, err := client.PendingNonceAt(context.Background(), fromAddress)
+	//gasLimit := uint64(21000)
+
+	// before the nonce, a price refused by the gas policy reserves none
+	price, err := PolicyGasPrice(client, nil)
 	if err != nil {
 		return nil, err
 	}
+	// refuse a chain without its expected id, not for a dry run which signs nothing
+	var chainID *big.Int
+	if !DryRun {
+		if chainID, err = SigningChainID(client); err != nil {
+			return nil, err
+		}
+	}
 
-	//gasLimit := uint64(21000)
-
-	// gas price
-	gasPrice, err := client.SuggestGasPrice(context.Background())
+	// get the nonce, it is reserved when the tx is submitted, so a tx built and dropped takes none
+	nonce, err := Nonces.Peek(client, fromAddress)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	// make tx
-	tx := types.NewTransaction(nonce, to, value, gasLimit, gasPrice, data)
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       to,
+		Value:    value,
+		Gas:      gasLimit,
+		GasPrice: price,
+		Data:     data,
+	})
 
-	// get the chainID
-	chainID, err := client.ChainID(context.Background())
-	if err != nil {
-		panic(err)
+	// a dry run keeps the tx unsigned
+	if DryRun {
+		dryRunFrom = fromAddress
+		return tx, nil
 	}
 
 	// sign tx
@@ -67,3 +94,38 @@ func MakeSignedTx(client *ethclient.Client,
 
 	return signedTx, nil
 }
+
+// the tx without its signature, and the hash a signer signs for it
+func Unsigned(signed *types.Transaction) (*types.Transaction, common.Hash) {
+	unsigned := types.NewTx(&types.LegacyTx{
+		Nonce:    signed.Nonce(),
+		To:       signed.To(),
+		Value:    signed.Value(),
+		Gas:      signed.Gas(),
+		GasPrice: signed.GasPrice(),
+		Data:     signed.Data(),
+	})
+
+	return unsigned, types.NewEIP155Signer(signed.ChainId()).Hash(unsigned)
+}
+
+// the gas price to sign with: the wanted one, or fixed by the chain's gas policy, or suggested.
+// it is refused above the max of the gas policy
+func PolicyGasPrice(client *ethclient.Client, want *big.Int) (*big.Int, error) {
+	price := want
+	if price == nil {
+		price = gasPrice
+	}
+	if price == nil {
+		var err error
+		price, err = client.SuggestGasPrice(context.Background())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if maxGasPrice != nil && price.Cmp(maxGasPrice) > 0 {
+		return nil, fmt.Errorf("gas price %s wei is above the max %s wei of the gas policy", price, maxGasPrice)
+	}
+
+	return price, nil
+}
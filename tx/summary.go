@@ -0,0 +1,111 @@
+package tx
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// an address shortened like 0xC4EA..f640
+func ShortAddr(a common.Address) string {
+	h := a.Hex()
+	return h[:6] + ".." + h[len(h)-4:]
+}
+
+// the role of an address and its short form, e.g. Provider 0xC4EA..f640
+func who(a common.Address) string {
+	switch a {
+	case common.HexToAddress(P_ADDR):
+		return "Provider " + ShortAddr(a)
+	case common.HexToAddress(U_ADDR):
+		return "User " + ShortAddr(a)
+	case common.HexToAddress(A_ADDR):
+		return "Admin " + ShortAddr(a)
+	}
+
+	return "Account " + ShortAddr(a)
+}
+
+// the field of a decoded arg as a string, empty when missing
+func field(v interface{}, path string) string {
+	f, err := FieldPath(v, path)
+	if err != nil {
+		return ""
+	}
+
+	return FormatValue(f)
+}
+
+// a credit amount field formatted with the token, the raw value otherwise
+func creditField(v interface{}, path string) string {
+	f, err := FieldPath(v, path)
+	if err != nil {
+		return ""
+	}
+	if n, ok := f.(*big.Int); ok {
+		return Credit.Format(n)
+	}
+
+	return FormatValue(f)
+}
+
+// seconds as a duration, in days when whole
+func secs(s string) string {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return s
+	}
+	if n.Sign() > 0 && new(big.Int).Mod(n, big.NewInt(86400)).Sign() == 0 {
+		return new(big.Int).Div(n, big.NewInt(86400)).String() + "d"
+	}
+
+	return n.String() + "s"
+}
+
+// the intent of calldata from an account, in plain language
+func Describe(from, to common.Address, data []byte) string {
+	contract, m, args, err := DecodeCall(to, data)
+	if err != nil {
+		return fmt.Sprintf("%s sends %d bytes of calldata to %s", who(from), len(data), ShortAddr(to))
+	}
+
+	on := fmt.Sprintf("on %s %s", strings.ToUpper(contract[:1])+contract[1:], ShortAddr(to))
+	var arg interface{}
+	if len(args) > 0 {
+		arg = args[0]
+	}
+
+	switch contract + "." + m.Name {
+	case "registry.register":
+		return fmt.Sprintf("%s registers CP '%s' at %s:%s %s", who(from), field(arg, "name"), field(arg, "ip"), field(arg, "port"), on)
+	case "registry.revise":
+		return fmt.Sprintf("%s revises CP '%s' to %s:%s, domain %s, %s", who(from), field(arg, "name"), field(arg, "ip"), field(arg, "port"), field(arg, "domain"), on)
+	case "registry.updatecp":
+		return fmt.Sprintf("%s updates CP '%s' to %s of %s nodes, %s of %s mem, %s of %s disk used %s", who(from), field(arg, "name"),
+			field(arg, "uNode"), field(arg, "nNode"), field(arg, "uMem"), field(arg, "nMem"), field(arg, "uDisk"), field(arg, "nDisk"), on)
+	case "registry.add_node":
+		return fmt.Sprintf("%s adds a node with cpu %s, gpu %s, mem %s, disk %s to its CP %s", who(from),
+			field(arg, "cpu.model"), field(arg, "gpu.model"), field(arg, "mem.num"), field(arg, "disk.num"), on)
+	case "credit.approve":
+		if len(args) < 2 {
+			break
+		}
+		spender := field(arg, "")
+		if spender == common.HexToAddress(Contracts.Market).Hex() {
+			spender = "Market " + ShortAddr(common.HexToAddress(spender))
+		}
+		return fmt.Sprintf("%s lets %s spend %s of its credit %s", who(from), spender, creditField(args[1], ""), on)
+	case "market.createOrder":
+		return fmt.Sprintf("%s orders node %s of %s for %s with %s probation, depositing %s %s", who(from),
+			field(arg, "nodeId"), who(common.HexToAddress(field(arg, "provider"))), secs(field(arg, "duration")), secs(field(arg, "probation")),
+			creditField(arg, "remain"), on)
+	case "market.userConfirm":
+		return fmt.Sprintf("%s confirms its order with %s %s", who(from), who(common.HexToAddress(field(arg, ""))), on)
+	case "market.userCancel":
+		return fmt.Sprintf("%s cancels its order with %s %s", who(from), who(common.HexToAddress(field(arg, ""))), on)
+	}
+
+	return fmt.Sprintf("%s calls %s %s", who(from), m.Sig, on)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -13,6 +14,9 @@ import (
 	"github.com/grid/contracts/eth"
 )
 
+// typed builders for every non-view method in the abis, check with go run ./gen -check
+//go:generate go run ./gen
+
 type Tx struct {
 	ep string
 	c  *ethclient.Client
@@ -34,219 +38,241 @@ func NewTx(ep string) *Tx {
 	return &Tx{ep, c, nil, nil}
 }
 
-// Make tx for register cp
-func (tx *Tx) MakeRegisterTx() error {
-	// tx data
-	data := RegisterData()
+// a nil tx on the same client, a Tx is not safe for concurrent use so each goroutine forks its own
+func (tx *Tx) Fork() *Tx {
+	return &Tx{tx.ep, tx.c, nil, nil}
+}
 
-	log.Println("making signed register tx")
-	// Make a signed tx
-	fmt.Println("cp sk: ", P_SK)
-	SignedTx, err := MakeSignedTx(tx.c, P_SK, common.HexToAddress(Contracts.Registry), nil, 1000000, data)
-	if err != nil {
+// chain id of the connected chain
+func (tx *Tx) ChainID() (*big.Int, error) {
+	return tx.c.ChainID(context.Background())
+}
+
+// Make tx for register cp
+func (tx *Tx) MakeRegisterTx(info *registry.IRegistryCP) error {
+	// the cp must be the signer
+	if err := CheckCP("register", P_SK, info); err != nil {
+		return err
+	}
+	if err := tx.PreflightRegister(info); err != nil {
 		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// tx data
+	data, err := RegistryRegisterData(*info)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Println("making signed register tx")
+	// Make a signed tx
+	log.Println("cp: ", P_ADDR)
+	return tx.MakeContractTx(P_SK, common.HexToAddress(Contracts.Registry), data)
 }
 
 // Make tx for update cp
-func (tx *Tx) MakeUpdateCPTx() error {
-	// tx data
-	data := UpdateCpData()
-
-	log.Println("making signed updatecp tx")
-	// Make a signed tx
-	fmt.Println("cp sk: ", P_SK)
-	SignedTx, err := MakeSignedTx(tx.c, P_SK, common.HexToAddress(Contracts.Registry), nil, 1000000, data)
-	if err != nil {
+func (tx *Tx) MakeUpdateCPTx(info *registry.IRegistryCP) error {
+	// the cp must be the signer
+	if err := CheckCP("updatecp", P_SK, info); err != nil {
 		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// tx data
+	data, err := RegistryUpdatecpData(*info)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Println("making signed updatecp tx")
+	// Make a signed tx
+	log.Println("cp: ", P_ADDR)
+	return tx.MakeContractTx(P_SK, common.HexToAddress(Contracts.Registry), data)
 }
 
 // add node tx
 func (tx *Tx) MakeAddNodeTx(node *registry.IRegistryNode) error {
-	// tx data
-	data := AddNodeData(node)
-
-	log.Println("making signed add node tx")
-	// Make a signed tx with data
-	fmt.Println("cp sk: ", P_SK)
-	SignedTx, err := MakeSignedTx(tx.c, P_SK, common.HexToAddress(Contracts.Registry), nil, 1000000, data)
-	if err != nil {
+	// the node must belong to the signer
+	if err := CheckNode(P_SK, node); err != nil {
+		return err
+	}
+	if err := tx.PreflightAddNode(node); err != nil {
 		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// tx data
+	data, err := RegistryAddNodeData(*node)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Println("making signed add node tx")
+	// Make a signed tx with data
+	log.Println("cp: ", P_ADDR)
+	return tx.MakeContractTx(P_SK, common.HexToAddress(Contracts.Registry), data)
 }
 
-// Make tx for approving credit to market
-func (tx *Tx) MakeApproveTx() error {
+// Make tx for approving amount of credit to market
+func (tx *Tx) MakeApproveTx(amount *big.Int) error {
 	// data for tx
-	data := ApproveData()
-
-	log.Println("making approve tx")
-	// Make a signed tx for approve to credit
-	SignedTx, err := MakeSignedTx(tx.c, U_SK, common.HexToAddress(Contracts.Credit), nil, 1000000, data)
+	data, err := CreditApproveData(common.HexToAddress(Contracts.Market), amount)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
-	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Printf("approving %s to market", Credit.Format(amount))
+	log.Println("making approve tx")
+	// Make a signed tx for approve to credit
+	return tx.MakeContractTx(U_SK, common.HexToAddress(Contracts.Credit), data)
 }
 
-// Make tx for create order
-func (tx *Tx) MakeCreateOrderTx() error {
-	// data for tx
-	data := CreateOrderData()
+// Make tx for create order with the order given by spec
+func (tx *Tx) MakeCreateOrderTx(spec *OrderSpec) error {
+	// deposit defaults to the node's price over the whole duration
+	if spec.Deposit == "" {
+		q, err := tx.QuoteOrder(spec)
+		if err != nil {
+			return fmt.Errorf("quote deposit: %w", err)
+		}
+
+		s := *spec
+		s.Deposit = WeiPrefix + q.Total.String()
+		spec = &s
+		log.Printf("deposit from quote: %s", Credit.Format(q.Total))
+	}
 
-	log.Println("making createorder tx")
-	// Make a signed tx for createorder, sender must be user
-	SignedTx, err := MakeSignedTx(tx.c, U_SK, common.HexToAddress(Contracts.Market), nil, 1000000, data)
+	// check spec and make the order
+	order, err := spec.Order()
 	if err != nil {
-		log.Fatal(err)
+		return invalid("createOrder: %v", err)
+	}
+	if err := CheckOrder(U_SK, order); err != nil {
+		return err
+	}
+	if err := tx.PreflightCreateOrder(common.HexToAddress(U_ADDR), order); err != nil {
+		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// data for tx
+	data, err := MarketCreateOrderData(*order)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Println("making createorder tx")
+	// Make a signed tx for createorder, sender must be user
+	return tx.MakeContractTx(U_SK, common.HexToAddress(Contracts.Market), data)
 }
 
 // Make tx for calling registry.revise
-func (tx *Tx) MakeReviseTx() error {
-	// data for tx
-	data := ReviseData()
-
-	log.Println("making registry.revise tx")
-	// Make a signed tx for revise, sender must be provider
-	SignedTx, err := MakeSignedTx(tx.c, P_SK, common.HexToAddress(Contracts.Registry), nil, 1000000, data)
-	if err != nil {
-		log.Fatal(err)
+func (tx *Tx) MakeReviseTx(info *registry.IRegistryCP) error {
+	// the cp must be the signer
+	if err := CheckCP("revise", P_SK, info); err != nil {
+		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// data for tx
+	data, err := RegistryReviseData(*info)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Println("making registry.revise tx")
+	// Make a signed tx for revise, sender must be provider
+	return tx.MakeContractTx(P_SK, common.HexToAddress(Contracts.Registry), data)
 }
 
-// Make tx for user confirm
-func (tx *Tx) MakeUserConfirmTx() error {
-	// data for tx
-	data := UserConfirmData()
-
-	log.Println("making user confirm tx")
-	// Make a signed tx for createorder, sender must be user
-	SignedTx, err := MakeSignedTx(tx.c, U_SK, common.HexToAddress(Contracts.Market), nil, 1000000, data)
-	if err != nil {
-		log.Fatal(err)
+// Make tx for user confirm of the order with provider
+func (tx *Tx) MakeUserConfirmTx(provider common.Address) error {
+	if err := CheckUserOp("userConfirm", U_SK, provider); err != nil {
+		return err
+	}
+	if err := tx.PreflightUserOp("userConfirm", common.HexToAddress(U_ADDR), provider); err != nil {
+		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// data for tx
+	data, err := MarketUserConfirmData(provider)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
-
-	return nil
+	log.Println("making user confirm tx")
+	// Make a signed tx for createorder, sender must be user
+	return tx.MakeContractTx(U_SK, common.HexToAddress(Contracts.Market), data)
 }
 
-func (tx *Tx) MakeUserCancelTx() error {
-	// data for tx
-	data := UserCancelData()
-
-	log.Println("making user cancel tx")
-	// Make a signed tx for createorder, sender must be user
-	SignedTx, err := MakeSignedTx(tx.c, U_SK, common.HexToAddress(Contracts.Market), nil, 1000000, data)
-	if err != nil {
-		log.Fatal(err)
+// Make tx for user cancel of the order with provider
+func (tx *Tx) MakeUserCancelTx(provider common.Address) error {
+	if err := CheckUserOp("userCancel", U_SK, provider); err != nil {
+		return err
+	}
+	if err := tx.PreflightUserOp("userCancel", common.HexToAddress(U_ADDR), provider); err != nil {
+		return err
 	}
 
-	// marshal tx into json
-	js, err := SignedTx.MarshalJSON()
+	// data for tx
+	data, err := MarketUserCancelData(provider)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	tx.SignedTx = SignedTx
-	tx.JsonTx = js
+	log.Println("making user cancel tx")
+	// Make a signed tx for createorder, sender must be user
+	return tx.MakeContractTx(U_SK, common.HexToAddress(Contracts.Market), data)
+}
 
-	return nil
+// a sent tx that reverted on chain
+type RevertError struct {
+	Hash common.Hash
+}
+
+func (e *RevertError) Error() string {
+	return fmt.Sprintf("tx %s reverted", e.Hash)
 }
 
 // send tx to chain
 func (tx *Tx) Send() error {
+	if err := tx.Submit(); err != nil {
+		return err
+	}
+
+	return tx.Wait()
+}
+
+// send the signed tx to the chain without waiting for it
+func (tx *Tx) Submit() error {
 	log.Printf("sending signed tx")
 
 	// send the tx to client
+	from, err := tx.From()
+	if err != nil {
+		return err
+	}
 	if err := tx.c.SendTransaction(context.Background(), tx.SignedTx); err != nil {
-		fmt.Println("send tx failed:", err.Error())
+		log.Println("send tx failed:", err.Error())
+		// the chain's pending nonce is used again
+		Nonces.Forget(from)
 		return err
 	}
+	// the next tx of the sender signs after it
+	Nonces.Sent(from, tx.SignedTx.Nonce())
+
+	return nil
+}
 
-	// wait tx ok
-	fmt.Println("waiting for tx to be ok")
+// wait for the submitted tx to be ok
+func (tx *Tx) Wait() error {
+	log.Println("waiting for tx to be ok")
 	err := eth.CheckTx(tx.ep, tx.SignedTx.Hash(), "")
 	if err != nil {
-		fmt.Println("tx failed:", err.Error())
+		log.Println("tx failed:", err.Error())
+		if receipt, rerr := tx.Receipt(); rerr == nil && receipt.Status == types.ReceiptStatusFailed {
+			return &RevertError{tx.SignedTx.Hash()}
+		}
 		return err
 	}
 
-	fmt.Println("tx ok")
+	log.Println("tx ok")
 
 	return nil
 }
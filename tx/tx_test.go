@@ -0,0 +1,88 @@
+package tx
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/eth/contracts"
+	"github.com/rockiecn/sendtx/internal/rpctest"
+)
+
+const approveABI = `[{"type":"function","name":"approve","stateMutability":"nonpayable",
+	"inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]}]`
+
+// a failed signing is an error of the Make*Tx, not an exit
+func TestMakeTxSigningErrors(t *testing.T) {
+	defer func(abi string, c contracts.Contracts, max *big.Int, id uint64) {
+		CreditABI, Contracts, maxGasPrice, signChainID = abi, c, max, id
+	}(CreditABI, Contracts, maxGasPrice, signChainID)
+	CreditABI = approveABI
+	Contracts.Credit = "0x00000000000000000000000000000000000000c1"
+	Contracts.Market = "0x00000000000000000000000000000000000000c2"
+
+	user := common.HexToAddress(U_ADDR)
+	nonceFails, chainIDFails := false, false
+	txObj := NewTx(rpctest.Serve(t, rpctest.Handlers{
+		"eth_chainId": func(json.RawMessage) (interface{}, error) {
+			if chainIDFails {
+				return nil, errors.New("chain id unavailable")
+			}
+			return "0x539", nil
+		},
+		"eth_gasPrice": rpctest.Answer("0x3b9aca00"),
+		"eth_getTransactionCount": func(json.RawMessage) (interface{}, error) {
+			if nonceFails {
+				return nil, errors.New("nonce unavailable")
+			}
+			return "0x7", nil
+		},
+	}))
+	defer Nonces.Forget(user)
+
+	signChainID = 1337
+
+	// above the max gas price of the policy
+	maxGasPrice = big.NewInt(1)
+	if err := txObj.MakeApproveTx(big.NewInt(5)); err == nil {
+		t.Fatal("approve above the max gas price made a tx")
+	}
+	if n, err := Nonces.Peek(txObj.c, user); err != nil || n != 7 {
+		t.Errorf("nonce after a refused gas price = %d, %v, want 7 still free", n, err)
+	}
+
+	// the nonce rpc fails
+	maxGasPrice = nil
+	nonceFails = true
+	if err := txObj.MakeApproveTx(big.NewInt(5)); err == nil {
+		t.Fatal("approve without a nonce made a tx")
+	}
+
+	nonceFails = false
+
+	// a chain without its expected id, or with another
+	for _, id := range []uint64{0, 1} {
+		signChainID = id
+		if err := txObj.MakeApproveTx(big.NewInt(5)); !IsValidation(err) {
+			t.Fatalf("approve for chain 1337 with expected id %d: %v, want a refusal", id, err)
+		}
+	}
+
+	signChainID = 1337
+
+	// the chain id rpc fails
+	chainIDFails = true
+	if err := txObj.MakeApproveTx(big.NewInt(5)); err == nil {
+		t.Fatal("approve without the chain id made a tx")
+	}
+
+	chainIDFails = false
+	if err := txObj.MakeApproveTx(big.NewInt(5)); err != nil {
+		t.Fatal(err)
+	}
+	if txObj.SignedTx.Nonce() != 7 || txObj.SignedTx.ChainId().Int64() != 1337 {
+		t.Errorf("approve signed nonce %d chain %s, want 7 and 1337", txObj.SignedTx.Nonce(), txObj.SignedTx.ChainId())
+	}
+}
@@ -0,0 +1,129 @@
+package tx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// the tx object of eth_sendTransaction and eth_signTransaction.
+// txs are signed as legacy txs like every tx here, so the eip-1559 fees are not used
+type TxArgs struct {
+	From                 *common.Address `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  *hexutil.Uint64 `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                *hexutil.Uint64 `json:"nonce"`
+	Data                 *hexutil.Bytes  `json:"data"`
+	Input                *hexutil.Bytes  `json:"input"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+}
+
+// the calldata, input wins over data like in geth
+func (a *TxArgs) CallData() ([]byte, error) {
+	if a.Input != nil && a.Data != nil && !bytes.Equal(*a.Input, *a.Data) {
+		return nil, fmt.Errorf("both data and input are set and differ")
+	}
+	if a.Input != nil {
+		return *a.Input, nil
+	}
+	if a.Data != nil {
+		return *a.Data, nil
+	}
+
+	return nil, nil
+}
+
+// the name of a call for policies: contract.method when the abis decode it,
+// else the target address with the method selector, or create for a contract creation
+func CallName(to *common.Address, data []byte) string {
+	if to == nil {
+		return "create"
+	}
+	if contract, m, _, err := DecodeCall(*to, data); err == nil {
+		return contract + "." + m.Name
+	}
+	if len(data) >= 4 {
+		return to.Hex() + "." + hexutil.Encode(data[:4])
+	}
+
+	return to.Hex()
+}
+
+// sign the tx of args with sk, filling the nonce, gas and gas price it leaves out.
+// the nonce is reserved when the tx is submitted
+func (tx *Tx) SignArgs(sk string, args *TxArgs) (*types.Transaction, error) {
+	key, err := crypto.HexToECDSA(sk)
+	if err != nil {
+		return nil, err
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	if args.From != nil && *args.From != from {
+		return nil, fmt.Errorf("from %s is not the signing account %s", args.From.Hex(), from.Hex())
+	}
+
+	chainID, err := SigningChainID(tx.c)
+	if err != nil {
+		return nil, err
+	}
+	if args.ChainID != nil && args.ChainID.ToInt().Cmp(chainID) != 0 {
+		return nil, invalid("chainId %s is not the chain's %s", args.ChainID.ToInt(), chainID)
+	}
+
+	data, err := args.CallData()
+	if err != nil {
+		return nil, err
+	}
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+
+	var gas uint64
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	} else {
+		estimated, err := tx.c.EstimateGas(context.Background(), ethereum.CallMsg{From: from, To: args.To, Value: value, Data: data})
+		if err != nil {
+			return nil, fmt.Errorf("estimate gas: %w", err)
+		}
+		gas = estimated * 12 / 10
+	}
+
+	var want *big.Int
+	if args.GasPrice != nil {
+		want = args.GasPrice.ToInt()
+	}
+	price, err := PolicyGasPrice(tx.c, want)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce uint64
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else if nonce, err = Nonces.Peek(tx.c, from); err != nil {
+		return nil, err
+	}
+
+	unsigned := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       args.To,
+		Value:    value,
+		Gas:      gas,
+		GasPrice: price,
+		Data:     data,
+	})
+
+	return types.SignTx(unsigned, types.NewEIP155Signer(chainID), key)
+}
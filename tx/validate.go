@@ -0,0 +1,160 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/grid/contracts/go/market"
+	"github.com/grid/contracts/go/registry"
+)
+
+// turn validation warnings into errors
+var Strict = false
+
+// address of the account owning sk
+func KeyAddress(sk string) (common.Address, error) {
+	key, err := crypto.HexToECDSA(sk)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(key.PublicKey), nil
+}
+
+// an operation refused by a local check before signing
+type ValidationError struct {
+	msg string
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &ValidationError{fmt.Sprintf(format, args...)}
+}
+
+// is err a refused operation, by a local check or a preflight
+func IsValidation(err error) bool {
+	var ve *ValidationError
+	var pe *PreflightError
+
+	return errors.As(err, &ve) || errors.As(err, &pe)
+}
+
+// log a warning, or fail with it in strict mode
+func warn(format string, args ...interface{}) error {
+	if Strict {
+		return invalid(format, args...)
+	}
+
+	log.Printf("warning: "+format, args...)
+	return nil
+}
+
+// the cp of register, revise and updatecp must be the signer, which should be the provider
+func CheckCP(op string, sk string, cp *registry.IRegistryCP) error {
+	signer, err := KeyAddress(sk)
+	if err != nil {
+		return err
+	}
+
+	if cp.Addr != signer {
+		return invalid("%s: cp address %s is not the signer %s", op, cp.Addr, signer)
+	}
+	if signer != common.HexToAddress(P_ADDR) {
+		return warn("%s: signer %s is not the configured provider %s", op, signer, P_ADDR)
+	}
+
+	return nil
+}
+
+// a node can only be added to the signer's own cp
+func CheckNode(sk string, node *registry.IRegistryNode) error {
+	signer, err := KeyAddress(sk)
+	if err != nil {
+		return err
+	}
+
+	if node.Cp != signer {
+		return invalid("add_node: node cp %s is not the signer %s", node.Cp, signer)
+	}
+	if signer != common.HexToAddress(P_ADDR) {
+		return warn("add_node: signer %s is not the configured provider %s", signer, P_ADDR)
+	}
+
+	return nil
+}
+
+// an order is made by a user with some other provider
+func CheckOrder(sk string, order *market.IMarketOrder) error {
+	return CheckUserOp("createOrder", sk, order.Provider)
+}
+
+// createOrder, userConfirm and userCancel are sent by the user, naming the order's provider
+func CheckUserOp(op string, sk string, provider common.Address) error {
+	signer, err := KeyAddress(sk)
+	if err != nil {
+		return err
+	}
+
+	if provider == signer {
+		return invalid("%s: provider %s is the signer itself", op, provider)
+	}
+	if provider == (common.Address{}) {
+		return invalid("%s: provider is the zero address", op)
+	}
+	if signer == common.HexToAddress(P_ADDR) {
+		return warn("%s: signed by the configured provider %s, not a user", op, signer)
+	}
+	if provider != common.HexToAddress(P_ADDR) && !hasRole(provider, "provider") {
+		return warn("%s: provider %s is not the configured provider %s", op, provider, P_ADDR)
+	}
+
+	return nil
+}
+
+// abi.ConvertType that returns an error instead of panicking on a mismatch
+func convertType(in, proto interface{}) (out interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot convert %T to %T: %v", in, proto, r)
+		}
+	}()
+
+	return abi.ConvertType(in, proto), nil
+}
+
+// run the checks of a known method on the args of a generic call
+func checkCall(contract string, m abi.Method, sk string, vals []interface{}) error {
+	switch contract + "." + m.Name {
+	case "registry.register", "registry.revise", "registry.updatecp":
+		cp, err := convertType(vals[0], new(registry.IRegistryCP))
+		if err != nil {
+			return warn("%s: cannot check args: %v", m.Name, err)
+		}
+		return CheckCP(m.Name, sk, cp.(*registry.IRegistryCP))
+	case "registry.add_node":
+		node, err := convertType(vals[0], new(registry.IRegistryNode))
+		if err != nil {
+			return warn("%s: cannot check args: %v", m.Name, err)
+		}
+		return CheckNode(sk, node.(*registry.IRegistryNode))
+	case "market.createOrder":
+		order, err := convertType(vals[0], new(market.IMarketOrder))
+		if err != nil {
+			return warn("%s: cannot check args: %v", m.Name, err)
+		}
+		return CheckOrder(sk, order.(*market.IMarketOrder))
+	case "market.userConfirm", "market.userCancel":
+		if provider, ok := vals[0].(common.Address); ok {
+			return CheckUserOp(m.Name, sk, provider)
+		}
+	}
+
+	return nil
+}
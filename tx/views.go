@@ -0,0 +1,139 @@
+package tx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/grid/contracts/go/market"
+	"github.com/grid/contracts/go/registry"
+)
+
+// a view read by the preflight, quote, compare and the agent. it is found in the loaded abi
+// by its name, else as the only view taking its inputs and returning its struct
+type View struct {
+	// registry or market
+	Contract string
+	// the method name looked up first
+	Name string
+	// abi types of the inputs
+	Inputs []string
+	// the abigen struct it returns, and the number of its fields for abis without internal types
+	Returns string
+	Fields  int
+}
+
+// the views of the cp of an address, the node of a cp by id, and the order of a user with a provider
+var (
+	CPView    = &View{"registry", "get_cp", []string{"address"}, "IRegistryCP", 11}
+	NodeView  = &View{"registry", "get_node", []string{"address", "uint64"}, "IRegistryNode", 6}
+	OrderView = &View{"market", "getOrder", []string{"address", "address"}, "IMarketOrder", 10}
+)
+
+// does the method take the view's inputs and return its struct
+func (v *View) matches(m abi.Method) bool {
+	if !m.IsConstant() || len(m.Inputs) != len(v.Inputs) || len(m.Outputs) != 1 {
+		return false
+	}
+	for i, in := range m.Inputs {
+		if in.Type.String() != v.Inputs[i] {
+			return false
+		}
+	}
+
+	out := m.Outputs[0].Type
+	if out.T != abi.TupleTy {
+		return false
+	}
+	if out.TupleRawName != "" {
+		return out.TupleRawName == v.Returns
+	}
+
+	return len(out.TupleElems) == v.Fields
+}
+
+// the name of the view in the loaded abi of its contract
+func (v *View) Method() (string, error) {
+	parsed, _, err := ContractByName(v.Contract)
+	if err != nil {
+		return "", err
+	}
+
+	if m, ok := parsed.Methods[v.Name]; ok && v.matches(m) {
+		return v.Name, nil
+	}
+	var found []string
+	for name, m := range parsed.Methods {
+		if v.matches(m) {
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+
+	switch len(found) {
+	case 1:
+		return found[0], nil
+	case 0:
+		return "", fmt.Errorf("the %s abi has no view %s(%s) returning %s", v.Contract, v.Name, strings.Join(v.Inputs, ","), v.Returns)
+	}
+	return "", fmt.Errorf("the %s abi has views %s all like %s, none named so", v.Contract, strings.Join(found, ", "), v.Name)
+}
+
+// call the view with args and convert its struct into proto
+func (tx *Tx) readView(v *View, proto interface{}, args ...interface{}) (interface{}, error) {
+	name, err := v.Method()
+	if err != nil {
+		return nil, err
+	}
+
+	abiJSON, to := RegABI, Contracts.Registry
+	if v.Contract == "market" {
+		abiJSON, to = MarketABI, Contracts.Market
+	}
+	out, err := tx.CallView(abiJSON, common.HexToAddress(to), name, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s returned nothing", name)
+	}
+
+	res, err := convertType(out[0], proto)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return res, nil
+}
+
+// read a cp from registry
+func (tx *Tx) GetCP(addr common.Address) (*registry.IRegistryCP, error) {
+	cp, err := tx.readView(CPView, new(registry.IRegistryCP), addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return cp.(*registry.IRegistryCP), nil
+}
+
+// read a node with its prices from registry
+func (tx *Tx) GetNode(cp common.Address, id uint64) (*registry.IRegistryNode, error) {
+	node, err := tx.readView(NodeView, new(registry.IRegistryNode), cp, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.(*registry.IRegistryNode), nil
+}
+
+// read the order of a user with a provider from market
+func (tx *Tx) GetOrder(user, provider common.Address) (*market.IMarketOrder, error) {
+	order, err := tx.readView(OrderView, new(market.IMarketOrder), user, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return order.(*market.IMarketOrder), nil
+}
@@ -0,0 +1,99 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// a registry abi with the cp view named name, returning a tuple with internal type internal
+func cpViewABI(name, internal string) string {
+	fields := []string{`{"name":"addr","type":"address"}`, `{"name":"name","type":"string"}`}
+	for _, f := range []string{"ip", "domain", "port"} {
+		fields = append(fields, fmt.Sprintf(`{"name":%q,"type":"string"}`, f))
+	}
+	for _, f := range []string{"nNode", "uNode", "nMem", "uMem", "nDisk", "uDisk"} {
+		fields = append(fields, fmt.Sprintf(`{"name":%q,"type":"uint64"}`, f))
+	}
+	out := fmt.Sprintf(`{"name":"","type":"tuple","internalType":%q,"components":[%s]}`, internal, strings.Join(fields, ","))
+
+	return fmt.Sprintf(`[{"type":"function","name":%q,"stateMutability":"view","inputs":[{"name":"cp","type":"address"}],"outputs":[%s]}]`, name, out)
+}
+
+func TestViewMethod(t *testing.T) {
+	defer func(old string) { RegABI = old }(RegABI)
+
+	tests := []struct {
+		name string
+		abi  string
+		want string
+		err  bool
+	}{
+		{"by name", cpViewABI("get_cp", "struct IRegistry.CP"), "get_cp", false},
+		{"renamed", cpViewABI("getCP", "struct IRegistry.CP"), "getCP", false},
+		{"without internal types", cpViewABI("cps", ""), "cps", false},
+		{"another struct", cpViewABI("get_cp", "struct IRegistry.Node"), "", true},
+		{"missing", `[]`, "", true},
+		{"two alike", "[" + strings.Trim(cpViewABI("a", "struct IRegistry.CP"), "[]") + "," + strings.Trim(cpViewABI("b", "struct IRegistry.CP"), "[]") + "]", "", true},
+	}
+
+	for _, tt := range tests {
+		RegABI = tt.abi
+		got, err := CPView.Method()
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Method() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPreflightViewStrict(t *testing.T) {
+	defer func(old string, strict bool) { RegABI, Strict = old, strict }(RegABI, Strict)
+	RegABI = `[]`
+
+	Strict = false
+	if _, ok, err := preflightView(CPView); ok || err != nil {
+		t.Errorf("missing view: ok %v err %v, want a skipped check", ok, err)
+	}
+
+	Strict = true
+	if _, ok, err := preflightView(CPView); ok || !IsValidation(err) {
+		t.Errorf("missing view in strict mode: ok %v err %v, want a validation error", ok, err)
+	}
+}
+
+type rpcErr struct {
+	code int
+	data interface{}
+}
+
+func (e *rpcErr) Error() string          { return "execution failed" }
+func (e *rpcErr) ErrorCode() int         { return e.code }
+func (e *rpcErr) ErrorData() interface{} { return e.data }
+
+func TestIsRevert(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"reverted tx", &RevertError{}, true},
+		{"revert code", &rpcErr{code: 3}, true},
+		{"revert data", fmt.Errorf("call get_cp: %w", &rpcErr{code: -32000, data: "0x08c379a0"}), true},
+		{"other rpc error", &rpcErr{code: -32000}, false},
+		{"data that is not revert data", &rpcErr{code: -32000, data: map[string]interface{}{"x": 1}}, false},
+		// matched by the message before
+		{"revert in a message", errors.New("dial tcp: lookup revert.example: no such host"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRevert(tt.err); got != tt.want {
+			t.Errorf("%s: IsRevert = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
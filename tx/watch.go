@@ -0,0 +1,214 @@
+package tx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// most blocks asked of eth_getLogs at once
+var LogsRange uint64 = 2000
+
+// which decoded events to keep, every set filter must match
+type EventFilter struct {
+	// event names like OrderCreated, any when empty
+	Names []string
+	// the provider or cp of the event
+	Provider *common.Address
+	// the user of the event
+	User *common.Address
+	// the node id of the event
+	Node *big.Int
+}
+
+// fields naming the provider, the user and the node in the events
+var (
+	providerFields = []string{"provider", "cp"}
+	userFields     = []string{"user"}
+	nodeFields     = []string{"nodeId", "node"}
+	// registry events whose plain id is the node's, other events' ids are not nodes
+	nodeIDEvents = map[string]bool{"AddNode": true}
+)
+
+// the fields that may name the node in the event
+func eventNodeFields(e *Event) []string {
+	if e.Contract == "registry" && nodeIDEvents[e.Name] {
+		return append(nodeFields, "id")
+	}
+
+	return nodeFields
+}
+
+// does the event pass the filter, an event without a filtered field does not
+func (f *EventFilter) Match(e *Event) bool {
+	if len(f.Names) > 0 {
+		found := false
+		for _, n := range f.Names {
+			if strings.EqualFold(n, e.Name) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Provider != nil && !hasField(e, providerFields, f.Provider.Hex()) {
+		return false
+	}
+	if f.User != nil && !hasField(e, userFields, f.User.Hex()) {
+		return false
+	}
+	if f.Node != nil && !hasField(e, eventNodeFields(e), f.Node.String()) {
+		return false
+	}
+
+	return true
+}
+
+// does one of the named fields of the event have the value, addresses in any case
+func hasField(e *Event, names []string, want string) bool {
+	for name, v := range e.Fields {
+		for _, n := range names {
+			if strings.EqualFold(name, n) && strings.EqualFold(FormatValue(v), want) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// the value of the first of the named fields the event has, as a string
+func eventField(e *Event, names []string) (string, bool) {
+	for _, n := range names {
+		for name, v := range e.Fields {
+			if strings.EqualFold(name, n) {
+				return FormatValue(v), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// the query of the logs of the contracts in use
+func contractsQuery() ethereum.FilterQuery {
+	return ethereum.FilterQuery{Addresses: []common.Address{
+		common.HexToAddress(Contracts.Registry),
+		common.HexToAddress(Contracts.Market),
+		common.HexToAddress(Contracts.Credit),
+	}}
+}
+
+// number of the latest block
+func (tx *Tx) HeadBlock() (uint64, error) {
+	return tx.c.BlockNumber(context.Background())
+}
+
+// the logs of the contracts in use from block from to block to, asked in ranges of LogsRange
+func (tx *Tx) ContractLogs(from, to uint64) ([]types.Log, error) {
+	var logs []types.Log
+	for start := from; start <= to; start += LogsRange {
+		end := start + LogsRange - 1
+		if end > to {
+			end = to
+		}
+
+		q := contractsQuery()
+		q.FromBlock = new(big.Int).SetUint64(start)
+		q.ToBlock = new(big.Int).SetUint64(end)
+		got, err := tx.c.FilterLogs(context.Background(), q)
+		if err != nil {
+			return nil, fmt.Errorf("logs of blocks %d to %d: %w", start, end, err)
+		}
+		logs = append(logs, got...)
+	}
+
+	return logs, nil
+}
+
+// follow new logs of the contracts in use, the rpc must be a websocket or ipc
+func (tx *Tx) SubscribeContractLogs(ch chan<- types.Log) (ethereum.Subscription, error) {
+	return tx.c.SubscribeFilterLogs(context.Background(), contractsQuery(), ch)
+}
+
+// the last block a watch has handled, to resume from
+type Checkpoint struct {
+	Chain string `json:"chain"`
+	// contract addresses, a checkpoint of other contracts is not resumed
+	Contracts []string `json:"contracts"`
+	Block     uint64   `json:"block"`
+}
+
+// read the checkpoint at path, nil when there is none yet
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cp, nil
+}
+
+// a checkpoint of the contracts in use on the chain
+func NewCheckpoint(chain string, block uint64) *Checkpoint {
+	return &Checkpoint{
+		Chain:     chain,
+		Contracts: []string{Contracts.Credit, Contracts.Registry, Contracts.Market},
+		Block:     block,
+	}
+}
+
+// is the checkpoint of the same chain and contracts
+func (cp *Checkpoint) Matches(other *Checkpoint) bool {
+	if cp.Chain != other.Chain || len(cp.Contracts) != len(other.Contracts) {
+		return false
+	}
+	for i := range cp.Contracts {
+		if !strings.EqualFold(cp.Contracts[i], other.Contracts[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// write the checkpoint at path, through a temp file so a crash keeps the old one
+func (cp *Checkpoint) Write(path string) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
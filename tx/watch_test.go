@@ -0,0 +1,25 @@
+package tx
+
+import (
+	"math/big"
+	"testing"
+)
+
+// an id is a node only in the events whose id names one
+func TestEventFilterNode(t *testing.T) {
+	filter := &EventFilter{Node: big.NewInt(3)}
+	for _, tc := range []struct {
+		event Event
+		want  bool
+	}{
+		{Event{Contract: "registry", Name: "AddNode", Fields: map[string]interface{}{"id": uint64(3)}}, true},
+		{Event{Contract: "market", Name: "OrderCreated", Fields: map[string]interface{}{"nodeId": uint64(3)}}, true},
+		{Event{Contract: "market", Name: "OrderCreated", Fields: map[string]interface{}{"nodeId": uint64(4)}}, false},
+		{Event{Contract: "market", Name: "OrderSettled", Fields: map[string]interface{}{"id": uint64(3)}}, false},
+		{Event{Contract: "registry", Name: "Register", Fields: map[string]interface{}{"id": uint64(3)}}, false},
+	} {
+		if got := filter.Match(&tc.event); got != tc.want {
+			t.Errorf("%s.%s %v: match %v, want %v", tc.event.Contract, tc.event.Name, tc.event.Fields, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/big"
+
+	"github.com/grid/contracts/go/registry"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// a command making one tx, also run as a scenario step
+type txOp struct {
+	name string
+	// name of the tx in the log
+	label string
+	// the role signing it by default: provider or user
+	role string
+	// add the flags of the op, and return how to make its tx once connected
+	setup func(fs *flag.FlagSet) func(txObj *tx.Tx) error
+}
+
+// parse the flags, connect, make the tx and finish it
+func (op txOp) run(args []string) error {
+	fs := newFlagSet(op.name)
+	o := options{}
+	o.register(fs, true)
+	o.asFlag(fs, op.role)
+	build := op.setup(fs)
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	restore, err := o.act(op.role)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if err := build(txObj); err != nil {
+		return err
+	}
+
+	return o.finish(txObj, op.label)
+}
+
+// the ops of the tx commands, by command name
+var txOps = map[string]txOp{
+	"register":     {"register", "registcp", "provider", registerOp},
+	"add-node":     {"add-node", "add node", "provider", addNodeOp},
+	"approve":      {"approve", "approve", "user", approveOp},
+	"create-order": {"create-order", "createorder", "user", createOrderOp},
+	"revise":       {"revise", "revise", "provider", reviseOp},
+	"confirm":      {"confirm", "userconfirm", "user", userOp("confirm")},
+	"cancel":       {"cancel", "usercancel", "user", userOp("cancel")},
+	"update-cp":    {"update-cp", "updatecp", "provider", updateCPOp},
+}
+
+// cp info flags of register, revise and update-cp
+type cpFlags struct {
+	name, ip, domain, port string
+}
+
+func (c *cpFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.name, "name", "cp1", "cp name")
+	fs.StringVar(&c.ip, "ip", "183.240.197.189", "cp ip")
+	fs.StringVar(&c.domain, "domain", "testdomain", "cp domain")
+	fs.StringVar(&c.port, "port", "41234", "cp port")
+}
+
+func (c *cpFlags) cp() *registry.IRegistryCP {
+	return tx.NewCP(c.name, c.ip, c.domain, c.port)
+}
+
+// order flags of create-order, also used by approve and quote.
+// the returned func reads the -spec file under the flags given, whatever their order
+func orderFlags(fs *flag.FlagSet, spec *tx.OrderSpec, deposit bool) func() error {
+	path := fs.String("spec", "", "order spec file like order.example.json, the order flags given override it")
+	fs.StringVar(&spec.Provider, "provider", "provider", "order provider, an address, alias or account: provider, user, admin, provider-3")
+	fs.Uint64Var(&spec.NodeId, "node", 1, "id of the provider's node")
+	fs.StringVar(&spec.Duration, "duration", "30d", "order duration, e.g. 2h or 30d")
+	if deposit {
+		fs.StringVar(&spec.Deposit, "deposit", "", "order deposit, e.g. 40, 40.5 CRD or wei:40000000, empty to quote it from the node prices")
+		fs.StringVar(&spec.Probation, "probation", "5s", "order probation, e.g. 2h or 1d")
+	}
+
+	return func() error {
+		if *path == "" {
+			return nil
+		}
+		flags := *spec
+		if err := spec.Load(*path); err != nil {
+			return usageError{err}
+		}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "provider":
+				spec.Provider = flags.Provider
+			case "node":
+				spec.NodeId = flags.NodeId
+			case "duration":
+				spec.Duration = flags.Duration
+			case "deposit":
+				spec.Deposit = flags.Deposit
+			case "probation":
+				spec.Probation = flags.Probation
+			}
+		})
+		return nil
+	}
+}
+
+// register the provider's cp, nodes are added with add-node
+func registerOp(fs *flag.FlagSet) func(*tx.Tx) error {
+	c := cpFlags{}
+	c.register(fs)
+
+	return func(txObj *tx.Tx) error {
+		// signed register tx for send to chain directly
+		return txObj.MakeRegisterTx(c.cp())
+	}
+}
+
+// add a node to the provider's cp
+func addNodeOp(fs *flag.FlagSet) func(*tx.Tx) error {
+	spec := tx.NodeSpec{}
+	fs.StringVar(&spec.CpuModel, "cpu", "i5", "cpu model")
+	fs.StringVar(&spec.CpuPrice, "cpu-price", "wei:25920000", "monthly cpu price, e.g. 10, 10 CRD or wei:25920000")
+	fs.StringVar(&spec.GpuModel, "gpu", "RTX4080", "gpu model")
+	fs.StringVar(&spec.GpuPrice, "gpu-price", "wei:259200000", "monthly gpu price")
+	fs.Uint64Var(&spec.Mem, "mem", 2592000, "memory amount")
+	fs.StringVar(&spec.MemPrice, "mem-price", "wei:259200000", "monthly memory price")
+	fs.Uint64Var(&spec.Disk, "disk", 2592000, "disk amount")
+	fs.StringVar(&spec.DiskPrice, "disk-price", "wei:25920000", "monthly disk price")
+
+	return func(txObj *tx.Tx) error {
+		node, err := spec.Node()
+		if err != nil {
+			return usageError{err}
+		}
+
+		return txObj.MakeAddNodeTx(node)
+	}
+}
+
+// approve credit to market, the quoted cost of an order by default
+func approveOp(fs *flag.FlagSet) func(*tx.Tx) error {
+	amount := fs.String("amount", "", "credit amount to approve, e.g. 40, 40.5 CRD or wei:40000000, empty to quote it from the order flags")
+	spec := tx.OrderSpec{}
+	loadSpec := orderFlags(fs, &spec, false)
+
+	return func(txObj *tx.Tx) error {
+		if err := loadSpec(); err != nil {
+			return err
+		}
+		// approve the given amount, or the quoted cost of the order
+		var value *big.Int
+		if *amount != "" {
+			v, err := tx.Credit.Parse(*amount)
+			if err != nil {
+				return usageError{err}
+			}
+			value = v
+		} else {
+			q, err := txObj.QuoteOrder(&spec)
+			if err != nil {
+				return err
+			}
+			value = q.Total
+		}
+
+		// tx for send to chain directly
+		return txObj.MakeApproveTx(value)
+	}
+}
+
+// create an order on a provider's node
+func createOrderOp(fs *flag.FlagSet) func(*tx.Tx) error {
+	spec := tx.OrderSpec{}
+	loadSpec := orderFlags(fs, &spec, true)
+
+	return func(txObj *tx.Tx) error {
+		if err := loadSpec(); err != nil {
+			return err
+		}
+		// signed market.createorder tx for send to chain directly
+		return txObj.MakeCreateOrderTx(&spec)
+	}
+}
+
+// revise the provider's cp info
+func reviseOp(fs *flag.FlagSet) func(*tx.Tx) error {
+	c := cpFlags{}
+	c.register(fs)
+
+	return func(txObj *tx.Tx) error {
+		// signed registry.revise tx for send to chain directly
+		return txObj.MakeReviseTx(c.cp())
+	}
+}
+
+// confirm or cancel the user's order with a provider, they only differ in the method
+func userOp(name string) func(*flag.FlagSet) func(*tx.Tx) error {
+	return func(fs *flag.FlagSet) func(*tx.Tx) error {
+		p := fs.String("provider", "provider", "provider of the order, an address, alias or account: provider, user, admin, provider-3")
+
+		return func(txObj *tx.Tx) error {
+			provider, err := tx.ResolveAddress(*p)
+			if err != nil {
+				return usageError{err}
+			}
+
+			if name == "confirm" {
+				err = txObj.MakeUserConfirmTx(provider)
+			} else {
+				err = txObj.MakeUserCancelTx(provider)
+			}
+			if err != nil {
+				return err
+			}
+
+			log.Printf("user %s order with provider %s", name, provider)
+
+			return nil
+		}
+	}
+}
+
+// update the provider's cp info and capacity
+func updateCPOp(fs *flag.FlagSet) func(*tx.Tx) error {
+	c := cpFlags{}
+	c.register(fs)
+	var nNode, uNode, nMem, uMem, nDisk, uDisk uint64
+	fs.Uint64Var(&nNode, "nnode", 0, "total nodes")
+	fs.Uint64Var(&uNode, "unode", 0, "used nodes")
+	fs.Uint64Var(&nMem, "nmem", 0, "total memory")
+	fs.Uint64Var(&uMem, "umem", 0, "used memory")
+	fs.Uint64Var(&nDisk, "ndisk", 0, "total disk")
+	fs.Uint64Var(&uDisk, "udisk", 0, "used disk")
+
+	return func(txObj *tx.Tx) error {
+		info := c.cp()
+		info.NNode, info.UNode = nNode, uNode
+		info.NMem, info.UMem = nMem, uMem
+		info.NDisk, info.UDisk = nDisk, uDisk
+
+		return txObj.MakeUpdateCPTx(info)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// the flags given win over the spec file wherever they are, the rest come from the file
+func TestOrderFlagsSpec(t *testing.T) {
+	want := tx.OrderSpec{Provider: "provider-3", NodeId: 2, Deposit: "40", Probation: "1h", Duration: "2d"}
+
+	for _, args := range [][]string{
+		{"-spec=order.example.json", "-deposit=40", "-duration=2d"},
+		{"-deposit=40", "-duration=2d", "-spec=order.example.json"},
+		// as serve and scenario pass their params
+		flagArgs(map[string]string{"spec": "order.example.json", "deposit": "40", "duration": "2d"}),
+	} {
+		fs := flag.NewFlagSet("create-order", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		spec := tx.OrderSpec{}
+		loadSpec := orderFlags(fs, &spec, true)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadSpec(); err != nil {
+			t.Fatal(err)
+		}
+		if spec != want {
+			t.Errorf("%q: spec %+v, want %+v", args, spec, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/rockiecn/sendtx/tx"
+)
+
+// follows the logs of the contracts and prints the decoded events
+type watcher struct {
+	o      *options
+	txObj  *tx.Tx
+	filter *tx.EventFilter
+
+	// file of the checkpoint, none when empty
+	checkpoint string
+	// the next block to read
+	next uint64
+}
+
+// follow the events of registry, market and credit:
+// sendtx watch -event OrderCreated -provider provider -checkpoint watch.json
+func watch(args []string) error {
+	fs := newFlagSet("watch")
+	o := options{}
+	o.register(fs, false)
+	events := fs.String("event", "", "comma separated event names to keep, e.g. OrderCreated,NodeAdded, all when empty")
+	provider := fs.String("provider", "", "keep the events of this provider, an address, alias or account profile")
+	user := fs.String("user", "", "keep the events of this user, an address, alias or account profile")
+	node := fs.String("node", "", "keep the events of this node id")
+	from := fs.String("from", "", "first block to read, a number or latest for the blocks after the head, default the block after the checkpoint or latest")
+	checkpoint := fs.String("checkpoint", "", "file keeping the last handled block, to resume from after a restart")
+	poll := fs.Bool("poll", false, "poll eth_getLogs even when the rpc is a websocket")
+	interval := fs.Duration("interval", 5*time.Second, "time between polls of eth_getLogs")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if outputMode != outText && outputMode != outNDJSON {
+		return usageError{fmt.Errorf("watch streams events, -output is text or ndjson")}
+	}
+
+	txObj, err := o.connect()
+	if err != nil {
+		return err
+	}
+
+	filter := &tx.EventFilter{}
+	if *events != "" {
+		filter.Names = strings.Split(*events, ",")
+	}
+	if *provider != "" {
+		addr, err := tx.ResolveAddress(*provider)
+		if err != nil {
+			return usageError{err}
+		}
+		filter.Provider = &addr
+	}
+	if *user != "" {
+		addr, err := tx.ResolveAddress(*user)
+		if err != nil {
+			return usageError{err}
+		}
+		filter.User = &addr
+	}
+	if *node != "" {
+		id, ok := new(big.Int).SetString(*node, 0)
+		if !ok {
+			return usageError{fmt.Errorf("invalid node id %q", *node)}
+		}
+		filter.Node = id
+	}
+
+	w := &watcher{o: &o, txObj: txObj, filter: filter, checkpoint: *checkpoint}
+	if err := w.start(*from); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	isWS := strings.HasPrefix(o.c.RPC, "ws://") || strings.HasPrefix(o.c.RPC, "wss://")
+	if isWS && !*poll {
+		err := w.subscribe(ctx)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("watch: subscription ended: %v, polling from block %d", err, w.next)
+	}
+
+	return w.poll(ctx, *interval)
+}
+
+// the first block: -from, else the block after the checkpoint, else the block after the head,
+// whose events are not new
+func (w *watcher) start(from string) error {
+	head, err := w.txObj.HeadBlock()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case from == "latest":
+		w.next = head + 1
+	case from != "":
+		n, err := strconv.ParseUint(from, 0, 64)
+		if err != nil {
+			return usageError{fmt.Errorf("invalid -from %q, want a block number or latest", from)}
+		}
+		w.next = n
+	default:
+		w.next = head + 1
+		if w.checkpoint == "" {
+			break
+		}
+		cp, err := tx.ReadCheckpoint(w.checkpoint)
+		if err != nil {
+			return err
+		}
+		if cp == nil {
+			break
+		}
+		if !cp.Matches(tx.NewCheckpoint(w.o.c.Label(), 0)) {
+			return fmt.Errorf("checkpoint %s is of chain %s with contracts %v, not of these, give -from or another -checkpoint", w.checkpoint, cp.Chain, cp.Contracts)
+		}
+		w.next = cp.Block + 1
+		log.Printf("watch: resuming after block %d", cp.Block)
+	}
+	log.Printf("watch: from block %d, head %d", w.next, head)
+
+	return nil
+}
+
+// read the logs up to the head and keep the checkpoint
+func (w *watcher) catchUp() error {
+	head, err := w.txObj.HeadBlock()
+	if err != nil {
+		return err
+	}
+	if head < w.next {
+		return nil
+	}
+
+	logs, err := w.txObj.ContractLogs(w.next, head)
+	if err != nil {
+		return err
+	}
+	for i := range logs {
+		w.handle(&logs[i])
+	}
+
+	return w.done(head)
+}
+
+// poll eth_getLogs until interrupted, failed polls are retried
+func (w *watcher) poll(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := w.catchUp(); err != nil {
+			log.Printf("watch: %v, retrying", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// follow new logs over the websocket, subscribed before catching up so no block is missed
+func (w *watcher) subscribe(ctx context.Context) error {
+	ch := make(chan types.Log, 256)
+	sub, err := w.txObj.SubscribeContractLogs(ch)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	if err := w.catchUp(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case l := <-ch:
+			// read while catching up
+			if l.BlockNumber < w.next && !l.Removed {
+				continue
+			}
+			// the blocks before it are handled
+			if l.BlockNumber > w.next {
+				if err := w.done(l.BlockNumber - 1); err != nil {
+					return err
+				}
+			}
+			w.handle(&l)
+		}
+	}
+}
+
+// blocks up to block are handled, save the checkpoint
+func (w *watcher) done(block uint64) error {
+	w.next = block + 1
+	if w.checkpoint == "" {
+		return nil
+	}
+
+	return tx.NewCheckpoint(w.o.c.Label(), block).Write(w.checkpoint)
+}
+
+// print the event of a log when it passes the filter
+func (w *watcher) handle(l *types.Log) {
+	ev, err := tx.DecodeLog(l)
+	if err != nil {
+		log.Printf("watch: block %d: %v", l.BlockNumber, err)
+		return
+	}
+	if !w.filter.Match(ev) {
+		return
+	}
+
+	if outputMode == outNDJSON {
+		b, err := json.Marshal(ev)
+		if err != nil {
+			log.Printf("watch: %v", err)
+			return
+		}
+		fmt.Println(string(b))
+		return
+	}
+
+	removed := ""
+	if ev.Removed {
+		removed = " removed by a reorg"
+	}
+	fmt.Printf("block %d tx %s %s.%s %s%s\n", ev.Block, ev.TxHash.Hex(), ev.Contract, ev.Name, formatFields(ev.Fields), removed)
+}